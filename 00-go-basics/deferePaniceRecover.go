@@ -74,6 +74,9 @@ func StartSever() {
 
 type ParsedStruct struct{}
 
+// SafeParsing turns a panic while parsing into an error. recover only catches
+// panics from the goroutine it runs in, so this (like Yep) covers a single
+// call; 07-concurrency/safego does the same for goroutines.
 func SafeParsing(data []byte) (ps ParsedStruct, err error){
 	defer func(){
 		if r := recover(); r != nil {
@@ -7,6 +7,7 @@ import (
 )
 
 func main() {
-	config.Load()
+	cfg := config.Load()
 	fmt.Println("all env variables are loaded!")
+	fmt.Printf("cors: %+v\n", cfg.CORS)
 }
@@ -4,14 +4,30 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
+
+	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	PORT int `env:"PORT" validate:"required,min=1,max=65535"`
+	PORT int  `env:"PORT" validate:"required,min=1,max=65535"`
+	CORS CORS `envPrefix:"CORS_"`
+}
+
+// CORS is what the cors middleware (08-http-server/cors) is built from. The
+// servers in the root module read the same variables with cors.FromEnv.
+type CORS struct {
+	AllowedOrigins        []string      `env:"ALLOWED_ORIGINS" envSeparator:"," envDefault:"http://localhost:3000"`
+	AllowedOriginPatterns []string      `env:"ALLOWED_ORIGIN_PATTERNS" envSeparator:","`
+	AllowedMethods        []string      `env:"ALLOWED_METHODS" envSeparator:"," envDefault:"GET,POST"`
+	AllowedHeaders        []string      `env:"ALLOWED_HEADERS" envSeparator:"," envDefault:"Authorization,Content-Type"`
+	ExposedHeaders        []string      `env:"EXPOSED_HEADERS" envSeparator:","`
+	AllowCredentials      bool          `env:"ALLOW_CREDENTIALS"`
+	MaxAge                time.Duration `env:"MAX_AGE" envDefault:"10m"`
 }
 
-func Load() {
+func Load() Config {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("error in loading in env vars!")
@@ -22,4 +38,10 @@ func Load() {
 		log.Fatal("port variable not defined")
 	}
 	fmt.Println(port)
+
+	var cfg Config
+	if err := env.Parse(&cfg); err != nil {
+		log.Fatal(err)
+	}
+	return cfg
 }
@@ -0,0 +1,264 @@
+// Package audit writes an append-only, hash-chained trail of security
+// relevant actions (logins, permission denials, deletes), kept apart from
+// the debug logs.
+//
+// Every line of the file is one JSON record:
+//
+//	{"entry":{"seq":1,"time":"...","action":"auth.login",...,"prev":"<hash of seq 0>"},"hash":"<sha256 of entry>"}
+//
+// The hash covers the exact bytes of "entry", and every entry carries the
+// hash of the one before it, so editing, deleting or reordering a line
+// breaks the chain. Cutting lines off the end is caught with the head file
+// (<path>.head) that holds the seq and hash of the last record written.
+//
+// This is tamper evidence against partial edits only. The chain is plain
+// SHA-256 with no key, and the head file sits next to the log, so anyone
+// who can write both can rewrite the whole file, recompute every hash and
+// update the head, and Verify will accept it. Ship the log (or at least
+// the head) somewhere the writer can't change, if that matters.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// Genesis is the prev hash of the first entry in a file.
+const Genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+var (
+	// ErrHeadMismatch means the log and its head file disagree: lines were
+	// cut off the end, or one of the two was replaced, while nothing was
+	// writing. Open refuses to continue such a chain.
+	ErrHeadMismatch = errors.New("audit: log does not match its head file")
+	// ErrIncomplete means the last line of the log is cut short, usually a
+	// write torn by a crash. Check the file with auditverify and repair it
+	// by hand; Open won't guess.
+	ErrIncomplete = errors.New("audit: last line of the log is incomplete")
+)
+
+// Outcome values for Event.Outcome.
+const (
+	Success = "success"
+	Failure = "failure"
+	Denied  = "denied"
+)
+
+// Event is what callers record.
+type Event struct {
+	Action  string         `json:"action"`
+	Actor   string         `json:"actor,omitempty"`
+	Target  string         `json:"target,omitempty"`
+	Outcome string         `json:"outcome,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// Entry is an Event as stored, with its place in the chain.
+type Entry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Event
+	Prev string `json:"prev"`
+}
+
+// record is one line of the file.
+type record struct {
+	Entry json.RawMessage `json:"entry"`
+	Hash  string          `json:"hash"`
+}
+
+// Head is the last link of the chain, persisted next to the log.
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Logger appends entries to an audit file. It is safe for concurrent use.
+type Logger struct {
+	mu       sync.Mutex
+	f        *os.File
+	size     int64 // end of the last complete record
+	headPath string
+	head     Head
+}
+
+// Open opens path for appending, creating it if needed, and picks the
+// chain up from the last line already in the file. That line has to match
+// the head file, so a log cut short while no Logger had it open is caught
+// here rather than silently continued; the one difference allowed is the
+// log being a single entry ahead, a crash between writing the entry and
+// its head.
+func Open(path string) (*Logger, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Logger{f: f, headPath: path + ".head"}
+	if err := l.resume(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) resume(path string) error {
+	head, lastPrev, err := lastHead(l.f)
+	if errors.Is(err, ErrIncomplete) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("audit: reading %s: %w", path, err)
+	}
+	stored, err := ReadHead(l.headPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if head.Seq != 0 {
+			return fmt.Errorf("%w: %s has %d entries but %s is missing", ErrHeadMismatch, path, head.Seq, l.headPath)
+		}
+	case err != nil:
+		return fmt.Errorf("audit: reading %s: %w", l.headPath, err)
+	case stored == head:
+	case head.Seq == stored.Seq+1 && lastPrev == stored.Hash:
+		// the entry made it to disk, its head didn't
+		if err := writeHead(l.headPath, head); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %s ends at seq %d, %s says seq %d", ErrHeadMismatch, path, head.Seq, l.headPath, stored.Seq)
+	}
+	info, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+	l.head, l.size = head, info.Size()
+	return nil
+}
+
+// Record appends e and syncs it to disk before returning.
+func (l *Logger) Record(e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return errors.New("audit: logger is closed")
+	}
+	entry, err := json.Marshal(Entry{
+		Seq:   l.head.Seq + 1,
+		Time:  time.Now().UTC(),
+		Event: e,
+		Prev:  l.head.Hash,
+	})
+	if err != nil {
+		return err
+	}
+	next := Head{Seq: l.head.Seq + 1, Hash: hash(entry)}
+	line, err := json.Marshal(record{Entry: entry, Hash: next.Hash})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := l.f.Write(line); err != nil {
+		return l.rollback(err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return l.rollback(err)
+	}
+	l.head, l.size = next, l.size+int64(len(line))
+	return writeHead(l.headPath, next)
+}
+
+// rollback cuts off whatever part of a failed record reached the file, so
+// the next one isn't appended after a broken line (or, if the record did
+// land but wasn't synced, doesn't repeat its seq).
+func (l *Logger) rollback(err error) error {
+	if terr := l.f.Truncate(l.size); terr != nil {
+		return errors.Join(err, fmt.Errorf("audit: truncating after failed write: %w", terr))
+	}
+	return err
+}
+
+// Close closes the file.
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func hash(entry []byte) string {
+	sum := sha256.Sum256(entry)
+	return hex.EncodeToString(sum[:])
+}
+
+// lastHead walks the file to find the last link and the prev hash that
+// entry carries. The file is not verified here, that's Verify's job, but
+// what it accepts as a line is the same.
+func lastHead(f *os.File) (head Head, prev string, err error) {
+	head, prev = Head{Hash: Genesis}, Genesis
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return head, prev, err
+	}
+	br := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		raw, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(raw) != 0 {
+				return head, prev, fmt.Errorf("%w: %s line %d, after seq %d", ErrIncomplete, f.Name(), line, head.Seq)
+			}
+			return head, prev, nil
+		}
+		if err != nil {
+			return head, prev, err
+		}
+		// Logger never writes one, so like Verify treat it as damage
+		if len(bytes.TrimSpace(raw)) == 0 {
+			return head, prev, fmt.Errorf("line %d: blank line", line)
+		}
+		var rec record
+		var e Entry
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return head, prev, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := json.Unmarshal(rec.Entry, &e); err != nil {
+			return head, prev, fmt.Errorf("line %d: %w", line, err)
+		}
+		head, prev = Head{Seq: e.Seq, Hash: rec.Hash}, e.Prev
+	}
+}
+
+func writeHead(path string, h Head) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadHead loads a head file written by Logger.
+func ReadHead(path string) (Head, error) {
+	var h Head
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return h, err
+	}
+	err = json.Unmarshal(b, &h)
+	return h, err
+}
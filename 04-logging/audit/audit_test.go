@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTemp(t *testing.T) (*Logger, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, path
+}
+
+func recordAll(t *testing.T, l *Logger, actions ...string) {
+	t.Helper()
+	for _, a := range actions {
+		if err := l.Record(Event{Action: a, Outcome: Success}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verifyFile(t *testing.T, path string) (int, error) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := ReadHead(path + ".head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Verify(bytes.NewReader(b), &head)
+}
+
+func TestRecordVerifyAndResume(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "auth.login", "user.create")
+	l.Close()
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordAll(t, l, "user.delete")
+	l.Close()
+
+	if n, err := verifyFile(t, path); n != 3 || err != nil {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+}
+
+func TestVerifyCatchesTampering(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a", "b", "c")
+	l.Close()
+	orig, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(orig), "\n")
+
+	for name, tampered := range map[string]string{
+		"modified":  strings.Replace(string(orig), `"action":"b"`, `"action":"x"`, 1),
+		"reordered": lines[0] + lines[2] + lines[1],
+		"removed":   lines[0] + lines[2],
+		"truncated": lines[0] + lines[1],
+	} {
+		os.WriteFile(path, []byte(tampered), 0o600)
+		var verr *VerifyError
+		if _, err := verifyFile(t, path); !errors.As(err, &verr) {
+			t.Errorf("%s: Verify = %v", name, err)
+		}
+	}
+}
+
+func TestBlankLineRejectedByOpenAndVerify(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a", "b")
+	l.Close()
+	b, _ := os.ReadFile(path)
+	first, rest, _ := bytes.Cut(b, []byte("\n"))
+	os.WriteFile(path, append(append(first, "\n\n"...), rest...), 0o600)
+
+	var verr *VerifyError
+	if _, err := verifyFile(t, path); !errors.As(err, &verr) || verr.Line != 2 {
+		t.Errorf("Verify = %v, want a failure on line 2", err)
+	}
+	if _, err := Open(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Open = %v, want a failure on line 2", err)
+	}
+}
+
+func TestOpenRefusesTornLine(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a")
+	l.Close()
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"entry":{"seq":2`)
+	f.Close()
+
+	if _, err := Open(path); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("Open = %v, want ErrIncomplete", err)
+	}
+}
+
+func TestOpenRefusesHeadMismatch(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a", "b", "c")
+	l.Close()
+	b, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(b), "\n")
+
+	// cut two entries off: more than the one a crash can leave behind
+	os.WriteFile(path, []byte(lines[0]), 0o600)
+	if _, err := Open(path); !errors.Is(err, ErrHeadMismatch) {
+		t.Errorf("Open = %v, want ErrHeadMismatch", err)
+	}
+	os.Remove(path + ".head")
+	if _, err := Open(path); !errors.Is(err, ErrHeadMismatch) {
+		t.Errorf("Open without head = %v, want ErrHeadMismatch", err)
+	}
+}
+
+func TestOpenCatchesUpOneEntryAhead(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a")
+	stale, _ := os.ReadFile(path + ".head")
+	recordAll(t, l, "b")
+	l.Close()
+	// the crash landed between writing entry 2 and its head
+	os.WriteFile(path+".head", stale, 0o600)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordAll(t, l, "c")
+	l.Close()
+	if n, err := verifyFile(t, path); n != 3 || err != nil {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+}
+
+func TestRollbackAfterFailedWrite(t *testing.T) {
+	l, path := openTemp(t)
+	recordAll(t, l, "a")
+
+	// half a record made it to disk before the write failed
+	l.f.WriteString(`{"entry":{"seq":2,"act`)
+	if err := l.rollback(errors.New("disk full")); err == nil {
+		t.Fatal("rollback swallowed the write error")
+	}
+	recordAll(t, l, "b")
+	l.Close()
+
+	if n, err := verifyFile(t, path); n != 2 || err != nil {
+		t.Fatalf("Verify = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// VerifyError says where the chain broke and why.
+type VerifyError struct {
+	Line   int
+	Seq    uint64
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	if e.Line == 0 {
+		return "audit: " + e.Reason
+	}
+	return fmt.Sprintf("audit: line %d (seq %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Verify reads an audit stream and checks every link of the chain. When
+// head is non-nil the stream must also end exactly at head, which is what
+// catches truncation. It returns the number of entries checked; any
+// failure is a *VerifyError.
+func Verify(r io.Reader, head *Head) (int, error) {
+	br := bufio.NewReader(r)
+	prev := Head{Hash: Genesis}
+	n := 0
+	for line := 1; ; line++ {
+		raw, err := br.ReadBytes('\n')
+		if err == io.EOF && len(raw) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if err == io.EOF {
+			// a partial last line means the file was cut mid-write
+			return n, &VerifyError{Line: line, Seq: prev.Seq + 1, Reason: "incomplete last line"}
+		}
+
+		if len(bytes.TrimSpace(raw)) == 0 {
+			return n, &VerifyError{Line: line, Seq: prev.Seq + 1, Reason: "malformed record: blank line"}
+		}
+		var rec record
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return n, &VerifyError{Line: line, Seq: prev.Seq + 1, Reason: "malformed record: " + err.Error()}
+		}
+		var e Entry
+		if err := json.Unmarshal(rec.Entry, &e); err != nil {
+			return n, &VerifyError{Line: line, Seq: prev.Seq + 1, Reason: "malformed entry: " + err.Error()}
+		}
+		switch {
+		case hash(rec.Entry) != rec.Hash:
+			return n, &VerifyError{Line: line, Seq: e.Seq, Reason: "entry does not match its hash (modified)"}
+		case e.Seq != prev.Seq+1:
+			return n, &VerifyError{Line: line, Seq: e.Seq, Reason: fmt.Sprintf("expected seq %d (reordered or removed entries)", prev.Seq+1)}
+		case e.Prev != prev.Hash:
+			return n, &VerifyError{Line: line, Seq: e.Seq, Reason: "prev hash does not match the previous entry"}
+		}
+		prev = Head{Seq: e.Seq, Hash: rec.Hash}
+		n++
+	}
+
+	switch {
+	case head == nil:
+	case head.Seq != prev.Seq:
+		return n, &VerifyError{Reason: fmt.Sprintf("stream ends at seq %d but head is seq %d (truncated)", prev.Seq, head.Seq)}
+	case head.Hash != prev.Hash:
+		return n, &VerifyError{Reason: "last entry does not match the head hash"}
+	}
+	return n, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/midsane/go-playground/04-logging/audit"
+)
+
+/*
+auditverify checks an audit file written by the audit package.
+exit code 0 -> chain is intact, 1 -> tampered, 2 -> couldn't run the check.
+
+usage: auditverify [-head file] [-no-head] <audit.log>
+*/
+
+func main() {
+	headPath := flag.String("head", "", "head file to check the end of the chain against (default <file>.head)")
+	noHead := flag.Bool("no-head", false, "skip the head check (truncation at the end won't be detected)")
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: auditverify [-head file] [-no-head] <audit.log>")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer f.Close()
+
+	var head *audit.Head
+	if !*noHead {
+		if *headPath == "" {
+			*headPath = args[0] + ".head"
+		}
+		h, err := audit.ReadHead(*headPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "reading head:", err)
+			os.Exit(2)
+		}
+		head = &h
+	}
+
+	n, err := audit.Verify(f, head)
+	var verr *audit.VerifyError
+	switch {
+	case errors.As(err, &verr):
+		fmt.Println("TAMPERED:", verr)
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Printf("ok: %d entries verified\n", n)
+}
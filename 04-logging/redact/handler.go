@@ -0,0 +1,63 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler is a slog.Handler that redacts a record before passing it on.
+// Attributes added through WithAttrs are redacted once, up front. A group
+// opened with WithGroup under a sensitive name, like "credentials" or
+// "auth_token", makes everything logged inside it sensitive too.
+type Handler struct {
+	next slog.Handler
+	r    *Redactor
+	// group is set once a WithGroup name matched a key fragment
+	group *Strategy
+}
+
+// NewHandler wraps next so that nothing r considers sensitive reaches it.
+func NewHandler(next slog.Handler, r *Redactor) *Handler {
+	return &Handler{next: next, r: r}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.String(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		if a = h.attr(a); !a.Equal(slog.Attr{}) {
+			out.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *Handler) attr(a slog.Attr) slog.Attr {
+	if h.group == nil {
+		return h.r.Attr(a)
+	}
+	a.Value = a.Value.Resolve()
+	return h.r.sensitive(a, *h.group)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a = h.attr(a); !a.Equal(slog.Attr{}) {
+			out = append(out, a)
+		}
+	}
+	return &Handler{next: h.next.WithAttrs(out), r: h.r, group: h.group}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	group := h.group
+	if s, ok := h.r.keyStrategy(name); ok && group == nil {
+		group = &s
+	}
+	return &Handler{next: h.next.WithGroup(name), r: h.r, group: group}
+}
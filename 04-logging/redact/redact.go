@@ -0,0 +1,386 @@
+// Package redact keeps secrets and PII out of log output.
+//
+// A Redactor looks at two things: the attribute key (authorization, password,
+// token, email, secret, ...) and the value itself (JWTs and email addresses
+// can show up under any key, or inside the log message). Whatever it finds is
+// replaced using one of three strategies: Drop, Hash or Mask.
+//
+// The usual way in is to wrap the sink handler:
+//
+//	r := redact.New(redact.Options{})
+//	logger := slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stdout, nil), r))
+package redact
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Strategy decides what a sensitive value turns into.
+type Strategy int
+
+const (
+	// Drop removes the attribute entirely. Inside a string (message or a
+	// value that only partly matches a pattern) the match becomes Placeholder.
+	Drop Strategy = iota
+	// Hash replaces the value with a short keyed digest, so equal values can
+	// still be correlated across log lines without being readable.
+	Hash
+	// Mask keeps a few characters at the edges and stars out the rest,
+	// e.g. "j***@example.com" or "ey**********J9".
+	Mask
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case Drop:
+		return "drop"
+	case Hash:
+		return "hash"
+	case Mask:
+		return "mask"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// Placeholder is what a dropped value inside a string is replaced with.
+const Placeholder = "[REDACTED]"
+
+// Pattern is a value pattern that gets redacted wherever it appears.
+type Pattern struct {
+	Name     string
+	Re       *regexp.Regexp
+	Strategy Strategy
+}
+
+var (
+	jwtRe   = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// Key is a sensitive key fragment and what to do with its values.
+type Key struct {
+	Fragment string
+	Strategy Strategy
+}
+
+// DefaultKeys returns the key fragments redacted when Options.Keys is nil.
+// Matching is case-insensitive and ignores '-' and '_', so "token" also
+// covers "access_token" and "X-Auth-Token". The Drop fragments come first,
+// so "email_token" is dropped rather than masked.
+func DefaultKeys() []Key {
+	return []Key{
+		{"authorization", Drop},
+		{"password", Drop},
+		{"secret", Drop},
+		{"token", Drop},
+		{"cookie", Drop},
+		{"email", Mask},
+	}
+}
+
+// DefaultPatterns returns the value patterns used when Options.Patterns is nil.
+func DefaultPatterns() []Pattern {
+	return []Pattern{
+		{Name: "jwt", Re: jwtRe, Strategy: Drop},
+		{Name: "email", Re: emailRe, Strategy: Mask},
+	}
+}
+
+// Options configures a Redactor. The zero value redacts DefaultKeys and
+// DefaultPatterns with an unkeyed hash.
+type Options struct {
+	// Keys are the fragments that make an attribute key sensitive. A key
+	// equal to a fragment uses its strategy, otherwise the first fragment
+	// the key contains wins.
+	Keys []Key
+	// Patterns are checked against every string value and the log message.
+	Patterns []Pattern
+	// HashKey is the HMAC key used by Hash. Without it the digest is a plain
+	// SHA-256, which is fine for correlation but guessable for emails.
+	HashKey []byte
+}
+
+// Redactor applies Options to attributes and strings. It is safe for
+// concurrent use.
+type Redactor struct {
+	keys     []Key
+	patterns []Pattern
+	hashKey  []byte
+}
+
+// New builds a Redactor from opts.
+func New(opts Options) *Redactor {
+	keys := opts.Keys
+	if keys == nil {
+		keys = DefaultKeys()
+	}
+	r := &Redactor{
+		keys:     make([]Key, 0, len(keys)),
+		patterns: opts.Patterns,
+		hashKey:  opts.HashKey,
+	}
+	for _, k := range keys {
+		r.keys = append(r.keys, Key{Fragment: normalizeKey(k.Fragment), Strategy: k.Strategy})
+	}
+	if r.patterns == nil {
+		r.patterns = DefaultPatterns()
+	}
+	return r
+}
+
+func normalizeKey(k string) string {
+	k = strings.ToLower(k)
+	k = strings.ReplaceAll(k, "-", "")
+	return strings.ReplaceAll(k, "_", "")
+}
+
+// keyStrategy reports whether key is sensitive and how to redact it.
+func (r *Redactor) keyStrategy(key string) (Strategy, bool) {
+	nk := normalizeKey(key)
+	for _, k := range r.keys {
+		if nk == k.Fragment {
+			return k.Strategy, true
+		}
+	}
+	for _, k := range r.keys {
+		if strings.Contains(nk, k.Fragment) {
+			return k.Strategy, true
+		}
+	}
+	return 0, false
+}
+
+// maxDepth bounds how far nested groups and values are followed; anything
+// deeper (or a pointer cycle) is replaced as a whole.
+const maxDepth = 8
+
+// Attr redacts a single attribute. A zero Attr is returned when the
+// attribute should be dropped; slog handlers ignore those.
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	return r.attr(a, 0)
+}
+
+func (r *Redactor) attr(a slog.Attr, depth int) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if s, ok := r.keyStrategy(a.Key); ok {
+		return r.sensitive(a, s)
+	}
+	if a.Value.Kind() == slog.KindAny {
+		// structs, maps and slices would be printed field by field by the
+		// sink, so turn them into groups and redact the fields by key
+		if attrs, ok := fields(a.Value.Any()); ok {
+			if len(attrs) == 0 {
+				// a struct with only unexported fields: there is nothing to
+				// redact by key, and its %v would print those fields as is
+				return slog.String(a.Key, Placeholder)
+			}
+			a.Value = slog.GroupValue(attrs...)
+		}
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		if depth >= maxDepth {
+			return slog.String(a.Key, Placeholder)
+		}
+		attrs := r.attrs(a.Value.Group(), depth+1)
+		if len(attrs) == 0 {
+			return slog.Attr{}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindString:
+		v, drop := r.scan(a.Value.String())
+		if drop {
+			return slog.Attr{}
+		}
+		a.Value = slog.StringValue(v)
+	case slog.KindAny:
+		// errors, Stringers and the like are formatted by the sink, so look
+		// at what they would print and only touch them if something matches.
+		orig := fmt.Sprintf("%+v", a.Value.Any())
+		v, drop := r.scan(orig)
+		if drop {
+			return slog.Attr{}
+		}
+		if v != orig {
+			a.Value = slog.StringValue(v)
+		}
+	}
+	return a
+}
+
+// Attrs redacts attrs, leaving out the ones that are dropped.
+func (r *Redactor) Attrs(attrs []slog.Attr) []slog.Attr {
+	return r.attrs(attrs, 0)
+}
+
+func (r *Redactor) attrs(attrs []slog.Attr, depth int) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a = r.attr(a, depth); !a.Equal(slog.Attr{}) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// fields lists the exported fields of a struct (named like encoding/json
+// would), the entries of a map with string keys or the elements of a slice
+// of composite values, as attributes. ok is false for anything else, for
+// empty maps and slices and for types that format themselves (errors,
+// Stringers, marshalers); those are scanned as text instead. A struct with
+// fields but none exported is ok with no attributes.
+func fields(v any) (attrs []slog.Attr, ok bool) {
+	switch v.(type) {
+	case error, fmt.Stringer, json.Marshaler, encoding.TextMarshaler, []byte:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		t := rv.Type()
+		if t.NumField() == 0 {
+			return nil, false
+		}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := f.Name
+			if tag, ok := f.Tag.Lookup("json"); ok {
+				n, _, _ := strings.Cut(tag, ",")
+				if n == "-" {
+					continue
+				}
+				name = cmp.Or(n, name)
+			}
+			attrs = append(attrs, slog.Any(name, rv.Field(i).Interface()))
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.Len() == 0 {
+			return nil, false
+		}
+		for it := rv.MapRange(); it.Next(); {
+			attrs = append(attrs, slog.Any(it.Key().String(), it.Value().Interface()))
+		}
+		slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	case reflect.Slice, reflect.Array:
+		switch rv.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Pointer, reflect.Interface:
+		default:
+			// plain values have no keys, scanning their text is enough
+			return nil, false
+		}
+		if rv.Len() == 0 {
+			return nil, false
+		}
+		for i := range rv.Len() {
+			attrs = append(attrs, slog.Any(strconv.Itoa(i), rv.Index(i).Interface()))
+		}
+	default:
+		return nil, false
+	}
+	return attrs, true
+}
+
+// sensitive redacts an attribute whose key matched. Groups are redacted leaf
+// by leaf so the shape of the record stays the same.
+func (r *Redactor) sensitive(a slog.Attr, s Strategy) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		var attrs []slog.Attr
+		for _, ga := range a.Value.Group() {
+			if ga = r.sensitive(slog.Attr{Key: ga.Key, Value: ga.Value.Resolve()}, s); !ga.Equal(slog.Attr{}) {
+				attrs = append(attrs, ga)
+			}
+		}
+		if len(attrs) == 0 {
+			return slog.Attr{}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	}
+	if s == Drop {
+		return slog.Attr{}
+	}
+	return slog.String(a.Key, r.apply(s, a.Value.String()))
+}
+
+// scan redacts pattern matches inside v. drop is set when a Drop pattern
+// matched the whole value, in which case the attribute should go away.
+func (r *Redactor) scan(v string) (out string, drop bool) {
+	out = v
+	for _, p := range r.patterns {
+		if p.Strategy == Drop {
+			if loc := p.Re.FindStringIndex(out); loc != nil && loc[0] == 0 && loc[1] == len(out) {
+				return "", true
+			}
+		}
+		out = p.Re.ReplaceAllStringFunc(out, func(m string) string {
+			return r.apply(p.Strategy, m)
+		})
+	}
+	return out, false
+}
+
+// String redacts every pattern match inside s. Use it for free-form text
+// such as log messages, where nothing can be dropped as a whole.
+func (r *Redactor) String(s string) string {
+	for _, p := range r.patterns {
+		s = p.Re.ReplaceAllStringFunc(s, func(m string) string {
+			return r.apply(p.Strategy, m)
+		})
+	}
+	return s
+}
+
+func (r *Redactor) apply(s Strategy, v string) string {
+	switch s {
+	case Hash:
+		return r.hash(v)
+	case Mask:
+		return mask(v)
+	}
+	return Placeholder
+}
+
+func (r *Redactor) hash(v string) string {
+	var sum []byte
+	if r.hashKey != nil {
+		m := hmac.New(sha256.New, r.hashKey)
+		m.Write([]byte(v))
+		sum = m.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(v))
+		sum = s[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// mask keeps the edges of v. Emails keep the first character of the local
+// part and the whole domain; anything under 8 characters is starred out.
+func mask(v string) string {
+	if at := strings.LastIndexByte(v, '@'); at > 0 && emailRe.MatchString(v) {
+		return v[:1] + "***" + v[at:]
+	}
+	if len(v) < 8 {
+		return strings.Repeat("*", len(v))
+	}
+	return v[:2] + strings.Repeat("*", len(v)-4) + v[len(v)-2:]
+}
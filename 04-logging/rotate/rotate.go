@@ -0,0 +1,394 @@
+// Package rotate is a file-backed io.Writer for hosts without a log agent.
+//
+// The active file is rotated when it grows past MaxSize or when the
+// RotateEvery interval rolls over. Rotated files are renamed with a
+// timestamp, optionally gzipped, and pruned to MaxBackups files / MaxAge.
+// On SIGHUP the file is reopened, so an external logrotate that moved it
+// away is picked up without restarting the process.
+//
+//	w, err := rotate.New(rotate.Options{Filename: "/var/log/app/app.log", MaxSize: 100 << 20, Compress: true, MaxBackups: 7})
+//	logger := slog.New(slog.NewJSONHandler(w, nil))
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// timeFormat is the timestamp put into rotated file names. It sorts
+// lexically in time order.
+const timeFormat = "20060102T150405.000"
+
+// Options configures a Writer. Filename is required; every other zero value
+// disables the feature it controls.
+type Options struct {
+	Filename string
+	// MaxSize rotates the file before a write would take it past this many bytes.
+	MaxSize int64
+	// RotateEvery rotates on wall-clock boundaries, e.g. time.Hour or
+	// 24*time.Hour. Boundaries are counted in UTC, so a daily file rolls
+	// over at midnight UTC whatever the local zone.
+	RotateEvery time.Duration
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// MaxBackups is how many rotated files to keep.
+	MaxBackups int
+	// MaxAge removes rotated files older than this.
+	MaxAge time.Duration
+	// Perm is the mode for new files, 0644 if zero.
+	Perm os.FileMode
+}
+
+// Writer is a rotating log file. It is safe for concurrent use.
+type Writer struct {
+	opts Options
+	now  func() time.Time
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	closed     bool
+
+	// mill compresses and prunes backups off the write path.
+	mill   chan struct{}
+	sighup chan os.Signal
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// ErrClosed is returned by writes after Close.
+var ErrClosed = errors.New("rotate: writer is closed")
+
+// New opens (or creates) opts.Filename for appending and starts the
+// background goroutines for compression and SIGHUP handling.
+func New(opts Options) (*Writer, error) {
+	return newWriter(opts, time.Now)
+}
+
+// newWriter is New with the clock the rotation schedule, backup names and
+// MaxAge are measured by.
+func newWriter(opts Options, now func() time.Time) (*Writer, error) {
+	if opts.Filename == "" {
+		return nil, errors.New("rotate: Filename is required")
+	}
+	if opts.Perm == 0 {
+		opts.Perm = 0o644
+	}
+	w := &Writer{
+		opts:   opts,
+		now:    now,
+		mill:   make(chan struct{}, 1),
+		sighup: make(chan os.Signal, 1),
+		done:   make(chan struct{}),
+	}
+	if err := os.MkdirAll(filepath.Dir(opts.Filename), 0o755); err != nil {
+		return nil, err
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	signal.Notify(w.sighup, syscall.SIGHUP)
+	w.wg.Add(2)
+	go w.runMill()
+	go w.runSignals()
+	// prune anything left over from a previous run
+	w.kickMill()
+	return w, nil
+}
+
+// Write appends p to the current file, rotating first if needed. A single
+// write is never split across two files. If the rotation fails, p still
+// goes to the current file and the error is reported on stderr; the next
+// write tries again.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "rotate:", err)
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate forces a rotation.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.rotate()
+}
+
+// Reopen closes and reopens Filename without renaming it. This is what
+// SIGHUP triggers. The old file stays in use if Filename can't be opened.
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.swap()
+}
+
+// Close flushes and closes the file and waits for pending compression.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.file.Close()
+	w.mu.Unlock()
+
+	signal.Stop(w.sighup)
+	close(w.done)
+	w.wg.Wait()
+	return err
+}
+
+func (w *Writer) shouldRotate(n int64) bool {
+	if w.opts.MaxSize > 0 && w.size > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.RotateEvery > 0 && !w.now().Before(w.nextRotate)
+}
+
+// open must be called with mu held (or before the writer is shared).
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.opts.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.opts.Perm)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	if w.opts.RotateEvery > 0 {
+		w.nextRotate = w.now().Truncate(w.opts.RotateEvery).Add(w.opts.RotateEvery)
+	}
+	return nil
+}
+
+// swap opens Filename and only then closes the current file, so a failed
+// open leaves the writer on the file it had. It must be called with mu held.
+func (w *Writer) swap() error {
+	old := w.file
+	if err := w.open(); err != nil {
+		return err
+	}
+	if err := old.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "rotate: closing old file:", err)
+	}
+	return nil
+}
+
+// rotate must be called with mu held. The file is renamed while still open,
+// so until the new one is open every write keeps a home; if that open
+// fails the rename is undone and nothing has rotated.
+func (w *Writer) rotate() error {
+	backup := w.backupName()
+	if err := os.Rename(w.opts.Filename, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := w.swap(); err != nil {
+		if uerr := os.Rename(backup, w.opts.Filename); uerr != nil && !errors.Is(uerr, os.ErrNotExist) {
+			// still writable, just under the backup's name until the next try
+			err = errors.Join(err, uerr)
+		}
+		return err
+	}
+	w.kickMill()
+	return nil
+}
+
+// backupName picks a timestamped name that is not taken yet, so two
+// rotations inside the same millisecond don't overwrite each other.
+func (w *Writer) backupName() string {
+	dir, prefix, ext := w.nameParts()
+	t := w.now().UTC()
+	for {
+		name := filepath.Join(dir, prefix+t.Format(timeFormat)+ext)
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (w *Writer) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.opts.Filename)
+	base := filepath.Base(w.opts.Filename)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func (w *Writer) kickMill() {
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Writer) runSignals() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.sighup:
+			if err := w.Reopen(); err != nil && !errors.Is(err, ErrClosed) {
+				fmt.Fprintln(os.Stderr, "rotate: reopen:", err)
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *Writer) runMill() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.mill:
+			if err := w.millOnce(); err != nil {
+				fmt.Fprintln(os.Stderr, "rotate:", err)
+			}
+		case <-w.done:
+			// one last pass so the file rotated right before Close is compressed
+			if err := w.millOnce(); err != nil {
+				fmt.Fprintln(os.Stderr, "rotate:", err)
+			}
+			return
+		}
+	}
+}
+
+type backup struct {
+	path string
+	t    time.Time
+	gz   bool
+}
+
+// millOnce compresses uncompressed backups and removes the ones that fall
+// outside the retention policy.
+func (w *Writer) millOnce() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+
+	var errs []error
+	cutoff := w.now().Add(-w.opts.MaxAge)
+	keep := backups[:0]
+	for i, b := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && b.t.Before(cutoff)) {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		keep = append(keep, b)
+	}
+	if w.opts.Compress {
+		for _, b := range keep {
+			if !b.gz {
+				if err := compress(b.path); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Writer) backups() ([]backup, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var out []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimPrefix(name, prefix)
+		gz := strings.HasSuffix(ts, ext+".gz")
+		ts = strings.TrimSuffix(strings.TrimSuffix(ts, ".gz"), ext)
+		t, err := time.Parse(timeFormat, ts)
+		if err != nil {
+			continue
+		}
+		out = append(out, backup{path: filepath.Join(dir, name), t: t, gz: gz})
+	}
+	return out, nil
+}
+
+// compress writes path+".gz" and removes path once the copy is synced.
+func compress(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,254 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeNow is a clock the test moves by hand. The mill goroutine reads it
+// too, hence the atomic.
+type fakeNow struct{ ns atomic.Int64 }
+
+func newFakeNow(t time.Time) *fakeNow {
+	c := &fakeNow{}
+	c.set(t)
+	return c
+}
+
+func (c *fakeNow) set(t time.Time)     { c.ns.Store(t.UnixNano()) }
+func (c *fakeNow) add(d time.Duration) { c.ns.Add(int64(d)) }
+func (c *fakeNow) now() time.Time      { return time.Unix(0, c.ns.Load()) }
+
+func newTestWriter(t *testing.T, opts Options, now func() time.Time) (*Writer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opts.Filename = filepath.Join(dir, "app.log")
+	if now == nil {
+		now = time.Now
+	}
+	w, err := newWriter(opts, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.Close() })
+	return w, dir
+}
+
+func write(t *testing.T, w *Writer, s string) {
+	t.Helper()
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// backupFiles lists the rotated files in dir, oldest first.
+func backupFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "app-") {
+			out = append(out, e.Name())
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatesOnSize(t *testing.T) {
+	w, dir := newTestWriter(t, Options{MaxSize: 10}, nil)
+	write(t, w, "12345\n")
+	write(t, w, "abcdef\n") // 13 bytes would pass MaxSize
+	// bigger than MaxSize on its own: rotated away from, but never split
+	write(t, w, strings.Repeat("x", 19)+"\n")
+	w.Close()
+
+	backups := backupFiles(t, dir)
+	if len(backups) != 2 {
+		t.Fatalf("backups %q, want 2", backups)
+	}
+	for i, want := range []string{"12345\n", "abcdef\n"} {
+		if got := read(t, filepath.Join(dir, backups[i])); got != want {
+			t.Errorf("%s = %q, want %q", backups[i], got, want)
+		}
+	}
+	if got := read(t, filepath.Join(dir, "app.log")); got != strings.Repeat("x", 19)+"\n" {
+		t.Errorf("app.log = %q", got)
+	}
+}
+
+func TestRotatesOnUTCBoundary(t *testing.T) {
+	clock := newFakeNow(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	w, dir := newTestWriter(t, Options{RotateEvery: 24 * time.Hour}, clock.now)
+	write(t, w, "a")
+
+	// local midnight somewhere east of UTC is no boundary
+	clock.set(time.Date(2026, 10, 20, 0, 0, 0, 0, time.FixedZone("IST", 5*3600+1800)))
+	write(t, w, "b")
+	clock.set(time.Date(2026, 10, 19, 23, 59, 59, 999e6, time.UTC))
+	write(t, w, "c")
+	if b := backupFiles(t, dir); len(b) != 0 {
+		t.Fatalf("rotated before midnight UTC: %q", b)
+	}
+
+	clock.set(time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC))
+	write(t, w, "d")
+	clock.add(23 * time.Hour)
+	write(t, w, "e")
+	w.Close()
+
+	backups := backupFiles(t, dir)
+	if want := []string{"app-20261020T000000.000.log"}; !slices.Equal(backups, want) {
+		t.Fatalf("backups %q, want %q", backups, want)
+	}
+	if got := read(t, filepath.Join(dir, backups[0])); got != "abc" {
+		t.Errorf("backup = %q, want abc", got)
+	}
+	if got := read(t, filepath.Join(dir, "app.log")); got != "de" {
+		t.Errorf("app.log = %q, want de", got)
+	}
+}
+
+func TestCompressesBackups(t *testing.T) {
+	w, dir := newTestWriter(t, Options{Compress: true}, nil)
+	write(t, w, "hello\n")
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	write(t, w, "world\n")
+	w.Close() // waits for the mill's last pass
+
+	backups := backupFiles(t, dir)
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("backups %q, want one .gz", backups)
+	}
+	f, err := os.Open(filepath.Join(dir, backups[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(zr); string(b) != "hello\n" {
+		t.Errorf("decompressed %q", b)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	// left by an earlier run: one too old for MaxAge, and a file that only
+	// looks like a backup
+	os.WriteFile(filepath.Join(dir, "app-20200101T000000.000.log"), []byte("old"), 0o644)
+	os.WriteFile(filepath.Join(dir, "app-notatime.log"), []byte("keep"), 0o644)
+
+	clock := newFakeNow(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	w, err := newWriter(Options{Filename: filepath.Join(dir, "app.log"), MaxBackups: 2, MaxAge: 24 * time.Hour}, clock.now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 4; i++ {
+		write(t, w, fmt.Sprint(i))
+		clock.add(time.Minute)
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+
+	backups := backupFiles(t, dir)
+	want := []string{"app-20261019T120300.000.log", "app-20261019T120400.000.log", "app-notatime.log"}
+	if !slices.Equal(backups, want) {
+		t.Fatalf("backups %q, want %q", backups, want)
+	}
+	if got := read(t, filepath.Join(dir, backups[0])) + read(t, filepath.Join(dir, backups[1])); got != "34" {
+		t.Errorf("kept %q, want the newest two", got)
+	}
+}
+
+func TestConcurrentWritesDuringRotation(t *testing.T) {
+	w, dir := newTestWriter(t, Options{MaxSize: 200}, nil)
+
+	const writers, lines = 8, 200
+	var wg sync.WaitGroup
+	for g := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range lines {
+				fmt.Fprintf(w, "g%d-%03d\n", g, i)
+			}
+		}()
+	}
+	// forced rotations and reopens racing the size-triggered ones
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				w.Rotate()
+				w.Reopen()
+			}
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	w.Close()
+
+	seen := make(map[string]int)
+	for _, name := range append(backupFiles(t, dir), "app.log") {
+		content := read(t, filepath.Join(dir, name))
+		if int64(len(content)) > 200 {
+			t.Errorf("%s is %d bytes, past MaxSize", name, len(content))
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			t.Errorf("%s ends mid-line: %q", name, content)
+		}
+		for _, l := range strings.Fields(content) {
+			seen[l]++
+		}
+	}
+	if len(seen) != writers*lines {
+		t.Errorf("%d distinct lines, want %d", len(seen), writers*lines)
+	}
+	for l, n := range seen {
+		if n != 1 {
+			t.Errorf("%q written %d times", l, n)
+		}
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	w, _ := newTestWriter(t, Options{}, nil)
+	w.Close()
+	if _, err := w.Write([]byte("x")); err != ErrClosed {
+		t.Errorf("Write after Close = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package apperr gives application errors a kind, a message that is safe to
+// show to clients and an internal detail that is not, while keeping the
+// cause reachable for errors.Is and errors.As.
+//
+//	var ErrUserNotFound = apperr.New(apperr.NotFound, "user not found")
+//
+//	if err := db.QueryRow(...).Scan(&u); err != nil {
+//		return apperr.Wrap(err, apperr.Internal, "").WithDetail("loading user %d", id)
+//	}
+//
+// Any error type can take part by implementing Kind() Kind and, optionally,
+// Public() string; see KindOf and PublicMessage.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Kind classifies an error by what the caller should do about it.
+type Kind int
+
+const (
+	// Other means "not classified here": the kind comes from the wrapped
+	// error, or is Internal if there is none.
+	Other Kind = iota
+	Invalid
+	Unauthorized
+	Forbidden
+	NotFound
+	Conflict
+	TooLarge
+	UnsupportedMediaType
+	Internal
+)
+
+var kindInfo = [...]struct {
+	slug   string
+	title  string
+	status int
+}{
+	Other:                {"other", "Internal Server Error", http.StatusInternalServerError},
+	Invalid:              {"invalid", "Bad Request", http.StatusBadRequest},
+	Unauthorized:         {"unauthorized", "Unauthorized", http.StatusUnauthorized},
+	Forbidden:            {"forbidden", "Forbidden", http.StatusForbidden},
+	NotFound:             {"not-found", "Not Found", http.StatusNotFound},
+	Conflict:             {"conflict", "Conflict", http.StatusConflict},
+	TooLarge:             {"too-large", "Content Too Large", http.StatusRequestEntityTooLarge},
+	UnsupportedMediaType: {"unsupported-media-type", "Unsupported Media Type", http.StatusUnsupportedMediaType},
+	Internal:             {"internal", "Internal Server Error", http.StatusInternalServerError},
+}
+
+func (k Kind) valid() bool { return k >= 0 && int(k) < len(kindInfo) }
+
+func (k Kind) String() string {
+	if !k.valid() {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindInfo[k].slug
+}
+
+// Status is the HTTP status code for k.
+func (k Kind) Status() int {
+	if !k.valid() {
+		return http.StatusInternalServerError
+	}
+	return kindInfo[k].status
+}
+
+// Title is the short, human readable summary of k.
+func (k Kind) Title() string {
+	if !k.valid() {
+		return kindInfo[Internal].title
+	}
+	return kindInfo[k].title
+}
+
+// Error is the application error type.
+type Error struct {
+	kind   Kind
+	msg    string
+	detail string
+	err    error
+}
+
+// New returns an error of the given kind. msg is shown to clients.
+func New(kind Kind, msg string) *Error {
+	return &Error{kind: kind, msg: msg}
+}
+
+// Wrap returns an error that keeps err as its cause. Pass Other to keep the
+// kind of err, and an empty msg to keep its public message.
+func Wrap(err error, kind Kind, msg string) *Error {
+	return &Error{kind: kind, msg: msg, err: err}
+}
+
+// WithDetail returns a copy of e with an internal detail. It shows up in
+// Error() and logs, never in a response. e itself is left alone so package
+// level sentinels stay intact; to keep errors.Is working against a
+// sentinel, wrap it first: Wrap(ErrUserNotFound, Other, "").WithDetail(...).
+func (e *Error) WithDetail(format string, args ...any) *Error {
+	c := *e
+	c.detail = fmt.Sprintf(format, args...)
+	return &c
+}
+
+// Kind reports the kind of e, falling back to its cause for Other.
+func (e *Error) Kind() Kind {
+	if e.kind != Other {
+		return e.kind
+	}
+	return KindOf(e.err)
+}
+
+// Public is the message safe to show to clients. Without one of its own, e
+// borrows its cause's, unless the cause is an internal error.
+func (e *Error) Public() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	var p publicer
+	if KindOf(e.err) != Internal && errors.As(e.err, &p) {
+		return p.Public()
+	}
+	return ""
+}
+
+// Detail is the internal detail set with WithDetail.
+func (e *Error) Detail() string { return e.detail }
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, 3)
+	if e.msg != "" {
+		parts = append(parts, e.msg)
+	}
+	if e.detail != "" {
+		parts = append(parts, e.detail)
+	}
+	if e.err != nil {
+		parts = append(parts, e.err.Error())
+	}
+	if len(parts) == 0 {
+		return e.Kind().String()
+	}
+	return strings.Join(parts, ": ")
+}
+
+func (e *Error) Unwrap() error { return e.err }
+
+type kinder interface{ Kind() Kind }
+
+type publicer interface{ Public() string }
+
+// KindOf returns the kind of the first error in err's chain that has one.
+// Errors that don't say are Internal.
+func KindOf(err error) Kind {
+	var k kinder
+	if errors.As(err, &k) {
+		if kind := k.Kind(); kind != Other {
+			return kind
+		}
+	}
+	return Internal
+}
+
+// PublicMessage returns what may be shown to a client for err. Internal
+// errors never leak their text; everything else uses the first Public()
+// in the chain, or the kind's title.
+func PublicMessage(err error) string {
+	kind := KindOf(err)
+	if kind == Internal {
+		return "internal server error"
+	}
+	var p publicer
+	if errors.As(err, &p) {
+		if msg := p.Public(); msg != "" {
+			return msg
+		}
+	}
+	return strings.ToLower(kind.Title())
+}
+
+// Is reports whether err is of the given kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
@@ -0,0 +1,61 @@
+package apperr
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// TypeBase prefixes the kind slug to form the problem "type" URI.
+var TypeBase = "/problems/"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// ProblemFor describes err for a client. Only the public message is used as
+// the detail; the error text itself is for logs.
+func ProblemFor(r *http.Request, err error) Problem {
+	kind := KindOf(err)
+	p := Problem{
+		Type:   TypeBase + kind.String(),
+		Title:  kind.Title(),
+		Status: kind.Status(),
+		Detail: PublicMessage(err),
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+// NewProblem is for the odd response that has no matching Kind, such as a
+// 405 from a router.
+func NewProblem(r *http.Request, status int, detail string) Problem {
+	p := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	return p
+}
+
+// Write renders p as application/problem+json.
+func (p Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Write renders err as application/problem+json.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	ProblemFor(r, err).Write(w)
+}
@@ -0,0 +1,182 @@
+// Package errs adds stack traces and aggregation on top of the standard
+// errors package. Everything here unwraps, so errors.Is and errors.As keep
+// working on the result.
+//
+// %v prints the message as usual; %+v prints the whole causal chain, each
+// link followed by the stack captured where it was created:
+//
+//	err := errs.Wrap(ErrUserNotFound, "loading profile")
+//	fmt.Printf("%+v\n", err)
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+)
+
+const maxDepth = 32
+
+// stack is the program counters captured when an error was created.
+type stack []uintptr
+
+func callers() stack {
+	var pcs [maxDepth]uintptr
+	// skip runtime.Callers, callers and the constructor that called it
+	n := runtime.Callers(3, pcs[:])
+	return pcs[:n]
+}
+
+func (s stack) write(w io.Writer, indent string) {
+	frames := runtime.CallersFrames(s)
+	for {
+		f, more := frames.Next()
+		if f.Function != "" {
+			fmt.Fprintf(w, "\n%s\t%s\n%s\t\t%s:%d", indent, f.Function, indent, f.File, f.Line)
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// withStack is an error with a message, an optional cause and the stack of
+// the place it was created.
+type withStack struct {
+	msg   string
+	cause error
+	stack stack
+	// formatted is set by Errorf: msg is already the full text and cause is
+	// the fmt error itself, which only matters for what it wraps.
+	formatted bool
+}
+
+func (e *withStack) Error() string {
+	switch {
+	case e.formatted || e.cause == nil:
+		return e.msg
+	case e.msg == "":
+		return e.cause.Error()
+	}
+	return e.msg + ": " + e.cause.Error()
+}
+
+func (e *withStack) Unwrap() error { return e.cause }
+
+func (e *withStack) Format(s fmt.State, verb rune) { format(s, verb, e) }
+
+// New returns an error with msg and the caller's stack.
+func New(msg string) error {
+	return &withStack{msg: msg, stack: callers()}
+}
+
+// Errorf is fmt.Errorf plus the caller's stack. %w works as usual.
+func Errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	return &withStack{msg: err.Error(), cause: err, stack: callers(), formatted: true}
+}
+
+// Wrap annotates err with msg and the caller's stack. Wrap(nil, ...) is nil.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &withStack{msg: msg, cause: err, stack: callers()}
+}
+
+// Wrapf is Wrap with a format string.
+func Wrapf(err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	return &withStack{msg: fmt.Sprintf(format, args...), cause: err, stack: callers()}
+}
+
+// WithStack records the caller's stack on err without changing its message.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &withStack{cause: err, stack: callers()}
+}
+
+// format implements fmt.Formatter for the types in this package.
+func format(s fmt.State, verb rune, err error) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		writeChain(s, err, "")
+	case verb == 'v' || verb == 's':
+		io.WriteString(s, err.Error())
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", err.Error())
+	default:
+		fmt.Fprintf(s, "%%!%c(%T)", verb, err)
+	}
+}
+
+// writeChain prints err and its stack, then every error it wraps. An
+// aggregate prints its members indented one level.
+func writeChain(w io.Writer, err error, indent string) {
+	for first := true; err != nil; first = false {
+		if !first {
+			io.WriteString(w, "\n"+indent+"caused by: ")
+		}
+		io.WriteString(w, strings.ReplaceAll(err.Error(), "\n", "\n"+indent))
+
+		var next []error
+		switch e := err.(type) {
+		case *withStack:
+			e.stack.write(w, indent)
+			next = []error{e.cause}
+			if e.formatted {
+				next = children(e.cause)
+			}
+		case *MultiError:
+			e.stack.write(w, indent)
+			next = e.errs
+		case *ValidationError:
+			e.stack.write(w, indent)
+			next = e.errs
+		default:
+			next = children(err)
+		}
+
+		switch len(next) {
+		case 0:
+			return
+		case 1:
+			err = next[0]
+		default:
+			for i, c := range next {
+				fmt.Fprintf(w, "\n%s[%d] ", indent+"  ", i)
+				writeChain(w, c, indent+"  ")
+			}
+			return
+		}
+	}
+}
+
+func children(err error) []error {
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if c := u.Unwrap(); c != nil {
+			return []error{c}
+		}
+	case interface{ Unwrap() []error }:
+		return u.Unwrap()
+	}
+	return nil
+}
+
+// As is errors.As with the target type as a type parameter, so the classic
+// mistakes (passing a non-pointer, or the address of something that isn't an
+// error) don't compile:
+//
+//	if perr, ok := errs.As[*permissionDenied](err); ok { ... }
+func As[T error](err error) (T, bool) {
+	var target T
+	ok := errors.As(err, &target)
+	return target, ok
+}
@@ -0,0 +1,107 @@
+package errs_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/midsane/go-playground/05-error-handling/errs"
+)
+
+var errRequired = errors.New("required")
+
+// validate fails on both fields; its frame is in the ValidationError's
+// stack.
+func validate() error {
+	var v errs.Validation
+	v.Add("email", errRequired)
+	v.Addf("age", "must be at least %d", 18)
+	return v.Err()
+}
+
+func TestIsThroughWrappers(t *testing.T) {
+	for name, err := range map[string]error{
+		"Wrap":       errs.Wrap(fs.ErrNotExist, "loading profile"),
+		"Wrapf":      errs.Wrapf(fs.ErrNotExist, "loading %s", "profile"),
+		"WithStack":  errs.WithStack(fs.ErrNotExist),
+		"Errorf":     errs.Errorf("loading profile: %w", fs.ErrNotExist),
+		"Wrap twice": errs.Wrap(errs.Wrap(fs.ErrNotExist, "inner"), "outer"),
+		"Join":       errs.Join(errors.New("other"), errs.Wrap(fs.ErrNotExist, "second")),
+		"Validation": errs.Wrap(validate(), "creating user"),
+	} {
+		want := fs.ErrNotExist
+		if name == "Validation" {
+			want = errRequired
+		}
+		if !errors.Is(err, want) {
+			t.Errorf("%s: errors.Is lost %v in %v", name, want, err)
+		}
+	}
+	if errs.Wrap(nil, "x") != nil || errs.Join(nil, nil) != nil || errs.WithStack(nil) != nil {
+		t.Error("wrapping nil isn't nil")
+	}
+}
+
+func TestAsThroughWrappers(t *testing.T) {
+	err := errs.Join(errors.New("db down"), errs.Wrap(validate(), "creating user"))
+
+	verr, ok := errs.As[*errs.ValidationError](err)
+	if !ok {
+		t.Fatalf("no *ValidationError in %v", err)
+	}
+	if got := verr.Fields(); len(got) != 2 || got["age"][0].Error() != "must be at least 18" {
+		t.Errorf("Fields = %v", got)
+	}
+	var fe *errs.FieldError
+	if !errors.As(err, &fe) || fe.Field != "email" {
+		t.Errorf("errors.As *FieldError = %v", fe)
+	}
+	var multi *errs.MultiError
+	if !errors.As(err, &multi) || len(multi.Errors()) != 2 {
+		t.Errorf("errors.As *MultiError = %v", multi)
+	}
+	if got := errs.Fields(err); len(got["email"]) != 1 {
+		t.Errorf("Fields through Join and Wrap = %v", got)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{errs.Wrap(fs.ErrNotExist, "loading"), "loading: file does not exist"},
+		{errs.WithStack(fs.ErrNotExist), "file does not exist"},
+		{errs.Errorf("loading %q: %w", "a", fs.ErrNotExist), `loading "a": file does not exist`},
+		{errs.Join(errors.New("a"), nil, errors.New("b")), "a; b"},
+		{validate(), "email: required; age: must be at least 18"},
+	} {
+		if got := fmt.Sprintf("%v", tc.err); got != tc.want {
+			t.Errorf("%%v = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestPlusVPrintsEveryStack(t *testing.T) {
+	out := fmt.Sprintf("%+v", errs.Wrap(validate(), "creating user"))
+
+	// the wrap, then the validation error with its own stack, then both
+	// fields indented under it
+	head, rest, ok := strings.Cut(out, "\ncaused by: ")
+	if !ok {
+		t.Fatalf("no cause in:\n%s", out)
+	}
+	if !strings.HasPrefix(head, "creating user: ") || !strings.Contains(head, "TestPlusVPrintsEveryStack") {
+		t.Errorf("wrap link:\n%s", head)
+	}
+	if !strings.Contains(rest, "errs_test.validate") {
+		t.Errorf("the ValidationError's stack is missing:\n%s", rest)
+	}
+	for _, field := range []string{"\n  [0] email: required", "\n  [1] age: must be at least 18"} {
+		if !strings.Contains(rest, field) {
+			t.Errorf("missing %q in:\n%s", field, rest)
+		}
+	}
+}
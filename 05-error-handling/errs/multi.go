@@ -0,0 +1,121 @@
+package errs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+// MultiError holds several errors. errors.Is and errors.As look at every one
+// of them.
+type MultiError struct {
+	errs  []error
+	stack stack
+}
+
+// Join is errors.Join with a stack: nil errors are dropped, and the result
+// is nil if nothing is left.
+func Join(errs ...error) error {
+	m := &MultiError{stack: callers()}
+	for _, err := range errs {
+		if err != nil {
+			m.errs = append(m.errs, err)
+		}
+	}
+	if len(m.errs) == 0 {
+		return nil
+	}
+	return m
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.errs))
+	for i, err := range m.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (m *MultiError) Unwrap() []error { return m.errs }
+
+func (m *MultiError) Format(s fmt.State, verb rune) { format(s, verb, m) }
+
+// Errors returns the collected errors.
+func (m *MultiError) Errors() []error { return m.errs }
+
+// FieldError ties an error to an input field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string { return e.Field + ": " + e.Err.Error() }
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// Fields groups the field errors in err by field name, in the order they
+// were added.
+func Fields(err error) map[string][]error {
+	out := map[string][]error{}
+	var walk func(error)
+	walk = func(err error) {
+		if fe, ok := err.(*FieldError); ok {
+			out[fe.Field] = append(out[fe.Field], fe.Err)
+			return
+		}
+		for _, c := range children(err) {
+			walk(c)
+		}
+	}
+	walk(err)
+	return out
+}
+
+// Validation collects field errors for one input. The zero value is ready
+// to use:
+//
+//	var v errs.Validation
+//	if u.Email == "" {
+//		v.Addf("email", "required")
+//	}
+//	return v.Err()
+type Validation struct {
+	errs []error
+}
+
+// Add records err against field. A nil err is ignored.
+func (v *Validation) Add(field string, err error) {
+	if err != nil {
+		v.errs = append(v.errs, &FieldError{Field: field, Err: err})
+	}
+}
+
+// Addf records a new error against field.
+func (v *Validation) Addf(field, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+}
+
+// Err returns the collected errors as a *ValidationError, or nil if there
+// are none.
+func (v *Validation) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{MultiError{errs: v.errs, stack: callers()}}
+}
+
+// ValidationError is a MultiError of field errors. apperr sees it as
+// Invalid, so it renders as a 400 that lists the fields.
+type ValidationError struct {
+	MultiError
+}
+
+func (e *ValidationError) Kind() apperr.Kind { return apperr.Invalid }
+
+func (e *ValidationError) Public() string { return e.Error() }
+
+// Fields is Fields(e).
+func (e *ValidationError) Fields() map[string][]error { return Fields(e) }
+
+func (e *ValidationError) Format(s fmt.State, verb rune) { format(s, verb, &e.MultiError) }
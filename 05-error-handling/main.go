@@ -1,10 +1,15 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 
+	"github.com/midsane/go-playground/04-logging/audit"
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+	"github.com/midsane/go-playground/05-error-handling/errs"
 	"go.uber.org/zap"
 )
 
@@ -13,7 +18,9 @@ type User struct {
 	email string
 }
 
-var ErrUserNotFound = errors.New("user not found")
+// sentinel errors are apperr values now -> errors.Is still works on them, and
+// apperr.Write turns them into a 404 problem response
+var ErrUserNotFound = apperr.New(apperr.NotFound, "user not found")
 
 func createuser() (User, error) {
 	//lets consider we got error while creating user
@@ -33,16 +40,45 @@ func (e *permissionDenied) Error() string {
 	return "user with userID:" + e.userID + " don't have permission for '" + e.action + "'"
 }
 
+// Kind and Public let apperr classify this type without it having to be an
+// *apperr.Error -> 403, and a message that is ok to send back
+func (e *permissionDenied) Kind() apperr.Kind {
+	return apperr.Forbidden
+}
+
+func (e *permissionDenied) Public() string {
+	return "you don't have permission to " + e.action
+}
+
 // lets also a .New to instansita an object of this type
 func New(userID string, action string) permissionDenied {
 	return permissionDenied{userID, action}
 }
 
-func authenticateUser(userID string) permissionDenied {
-	return permissionDenied{
+// returns error now, not permissionDenied -> Error() has a pointer receiver so
+// only *permissionDenied is an error, and callers should only see the interface
+func authenticateUser(userID string) error {
+	return errs.WithStack(&permissionDenied{
 		userID: userID,
 		action: "loggin in",
+	})
+}
+
+// field errors are collected instead of returning on the first one
+func validateUser(u User) error {
+	var v errs.Validation
+	if u.name == "" {
+		v.Addf("name", "required")
 	}
+	if u.email == "" {
+		v.Addf("email", "required")
+	}
+	return v.Err()
+}
+
+func loadProfile(userID string) error {
+	_, err := createuser()
+	return errs.Wrapf(err, "loading profile of userID %s", userID)
 }
 
 func main() {
@@ -52,18 +88,49 @@ func main() {
 	}
 	fmt.Println(user)
 
+	//wrapping keeps the sentinel reachable and adds detail only meant for logs
+	wrapped := apperr.Wrap(ErrUserNotFound, apperr.Other, "").WithDetail("looking up userID %d", 2)
+	fmt.Println(errors.Is(wrapped, ErrUserNotFound), apperr.KindOf(wrapped), wrapped)
+	fmt.Printf("%+v\n", apperr.ProblemFor(nil, wrapped))
+
 	pd := New("2", "loggin in")
 	fmt.Println(pd.Error())
 
 	//now lets consider returning htis error type
 	err2 := authenticateUser("2")
 	fmt.Println("err2:", err2)
-	var perr *permissionDenied
-	if errors.As(&err2, &perr) {
+	//errors.As(&err2, &perr) used to compile even though &err2 was never the error
+	//we meant to inspect. errs.As takes the target type as a type param, so the
+	//target is always a valid error type and err2 has to be an error
+	if perr, ok := errs.As[*permissionDenied](err2); ok {
 		slog.Info("permission denied")
-		zap.String("user_id", err2.userID)
-		zap.String("action", err2.action)
+		zap.String("user_id", perr.userID)
+		zap.String("action", perr.action)
+
+		//denials also belong in the audit trail, not just the debug logs
+		if err := recordDenial(perr); err != nil {
+			slog.Error("audit", "err", err)
+		}
+	}
+
+	//%+v prints every link of the chain with the stack where it was created
+	err3 := loadProfile("2")
+	fmt.Printf("%+v\n", err3)
+	fmt.Println(errors.Is(err3, ErrUserNotFound))
+
+	err4 := validateUser(User{})
+	fmt.Println(err4, errs.Fields(err4), apperr.KindOf(err4))
+}
+
+// AUDIT_LOG picks the file; by default this example keeps its own so it
+// never forks the chain of a server run from the same directory
+func recordDenial(perr *permissionDenied) error {
+	al, err := audit.Open(cmp.Or(os.Getenv("AUDIT_LOG"), "error-handling-audit.log"))
+	if err != nil {
+		return err
 	}
+	err = al.Record(audit.Event{Action: perr.action, Actor: perr.userID, Outcome: audit.Denied})
+	return errors.Join(err, al.Close())
 }
 
 
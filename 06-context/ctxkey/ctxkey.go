@@ -0,0 +1,55 @@
+// Package ctxkey replaces raw context.WithValue keys with typed ones.
+//
+// A string key like context.WithValue(ctx, "user", id) can collide with any
+// other package using "user", and reading it back needs an unchecked type
+// assertion that panics when the middleware that sets it wasn't run. A
+// Key[T] is unique per New call and only stores and returns T:
+//
+//	var userKey = ctxkey.New[string]("user")
+//
+//	ctx = userKey.With(ctx, id)
+//	id, ok := userKey.From(ctx)
+package ctxkey
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key is a typed context key. Keys are compared by pointer, so two keys
+// with the same name never see each other's values.
+type Key[T any] struct {
+	name string
+}
+
+// New returns a new key. name is only used in messages.
+func New[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// With returns a copy of ctx carrying v under k.
+func (k *Key[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// From returns the value stored under k, and whether there was one.
+func (k *Key[T]) From(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// MustFrom is From for values that are always set by the time the caller
+// runs, e.g. by a middleware the route can't be reached without. It panics
+// with the key name otherwise, which is a wiring bug, not a request error.
+func (k *Key[T]) MustFrom(ctx context.Context) T {
+	v, ok := k.From(ctx)
+	if !ok {
+		panic(fmt.Sprintf("ctxkey: %s not set in context", k))
+	}
+	return v
+}
+
+func (k *Key[T]) String() string {
+	var zero T
+	return fmt.Sprintf("%s (%T)", k.name, zero)
+}
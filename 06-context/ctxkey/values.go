@@ -0,0 +1,66 @@
+package ctxkey
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	UserID string
+	Email  string
+	Roles  []string
+}
+
+var (
+	principalKey = New[Principal]("principal")
+	requestIDKey = New[string]("request id")
+	tenantKey    = New[string]("tenant")
+	loggerKey    = New[*slog.Logger]("logger")
+)
+
+// WithPrincipal stores the authenticated caller, usually from auth middleware.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return principalKey.With(ctx, p)
+}
+
+// PrincipalFrom returns the authenticated caller, if there is one.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	return principalKey.From(ctx)
+}
+
+// WithRequestID stores the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return requestIDKey.With(ctx, id)
+}
+
+// RequestID returns the request ID, or "" if none was set.
+func RequestID(ctx context.Context) string {
+	id, _ := requestIDKey.From(ctx)
+	return id
+}
+
+// WithTenant stores the tenant the request is scoped to.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return tenantKey.With(ctx, tenant)
+}
+
+// Tenant returns the tenant, or "" if none was set.
+func Tenant(ctx context.Context) string {
+	t, _ := tenantKey.From(ctx)
+	return t
+}
+
+// WithLogger stores a request-scoped logger.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return loggerKey.With(ctx, l)
+}
+
+// Logger returns the request-scoped logger, or slog.Default() so callers
+// never have to nil-check.
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := loggerKey.From(ctx); ok && l != nil {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,82 @@
+// Package deadline carries a caller's deadline across HTTP hops.
+//
+// The client side (Transport) turns the request context's deadline into an
+// X-Request-Timeout header, encoded like grpc-timeout ("250m" is 250ms).
+// The server side (Middleware) reads it back, caps it with the server's own
+// policy and puts the result on the request context. Any outbound call a
+// handler makes with that context and a Transport-backed client passes the
+// remaining budget along, so the whole chain gives up at the same time.
+//
+// A relative timeout is sent instead of an absolute time so clock skew
+// between hosts doesn't matter; the time spent on the wire is simply
+// counted against the callee.
+package deadline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Header is the request header carrying the remaining budget.
+const Header = "X-Request-Timeout"
+
+var units = []struct {
+	suffix byte
+	d      time.Duration
+}{
+	{'H', time.Hour},
+	{'M', time.Minute},
+	{'S', time.Second},
+	{'m', time.Millisecond},
+	{'u', time.Microsecond},
+	{'n', time.Nanosecond},
+}
+
+// maxDigits is the grpc-timeout limit on the number part.
+const maxDigits = 8
+
+// Encode formats d as a grpc-timeout value, using the finest unit that fits
+// in 8 digits. Non-positive durations encode as "0n".
+func Encode(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	for i := len(units) - 1; i >= 0; i-- {
+		u := units[i]
+		// truncate so the callee never gets more time than the caller has;
+		// a unit too coarse to hold any of d is skipped for a finer one
+		n := d / u.d
+		if n > 0 && len(strconv.FormatInt(int64(n), 10)) <= maxDigits {
+			return strconv.FormatInt(int64(n), 10) + string(u.suffix)
+		}
+	}
+	return "99999999H"
+}
+
+// ErrBadTimeout is returned by Decode for malformed values.
+var ErrBadTimeout = errors.New("deadline: malformed timeout")
+
+// Decode parses a grpc-timeout value.
+func Decode(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > maxDigits+1 {
+		return 0, fmt.Errorf("%w: %q", ErrBadTimeout, s)
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %q", ErrBadTimeout, s)
+	}
+	for _, u := range units {
+		if u.suffix == s[len(s)-1] {
+			// 8 digits of hours is far past what a Duration holds; saturate
+			// so the caller's policy caps it instead of it going negative
+			if n > math.MaxInt64/int64(u.d) {
+				return math.MaxInt64, nil
+			}
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown unit in %q", ErrBadTimeout, s)
+}
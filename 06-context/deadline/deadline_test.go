@@ -0,0 +1,145 @@
+package deadline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0n"},
+		{-time.Second, "0n"},
+		{1, "1n"},
+		{250 * time.Millisecond, "250000u"},
+		{1500 * time.Microsecond, "1500000n"},
+		{time.Hour, "3600000m"},
+		{math.MaxInt64, "2562047H"},
+	} {
+		if got := Encode(tc.d); got != tc.want {
+			t.Errorf("Encode(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeNeverGivesMoreTime(t *testing.T) {
+	// none of these are whole multiples of the unit Encode settles on
+	for _, d := range []time.Duration{
+		123456789,
+		1500*time.Millisecond + 1,
+		99999999*time.Microsecond + 999,
+		25*time.Hour + 1,
+	} {
+		got, err := Decode(Encode(d))
+		if err != nil {
+			t.Fatalf("Decode(Encode(%v)): %v", d, err)
+		}
+		if got > d {
+			t.Errorf("%v encoded as %q, which is %v", d, Encode(d), got)
+		}
+	}
+	if got := Encode(123456789); got != "123456u" {
+		t.Errorf("Encode(123456789ns) = %q, want it truncated to 123456u", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"0n":        0,
+		"250m":      250 * time.Millisecond,
+		"1500u":     1500 * time.Microsecond,
+		"3S":        3 * time.Second,
+		"2M":        2 * time.Minute,
+		"99999999H": math.MaxInt64, // saturates instead of overflowing
+	} {
+		got, err := Decode(in)
+		if err != nil || got != want {
+			t.Errorf("Decode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "5", "m", "-1m", "1.5S", "5x", "123456789m"} {
+		if _, err := Decode(in); !errors.Is(err, ErrBadTimeout) {
+			t.Errorf("Decode(%q) = %v, want ErrBadTimeout", in, err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got time.Duration
+	var hasDeadline bool
+	h := Middleware(Policy{Default: time.Second, Max: 2 * time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var dl time.Time
+		dl, hasDeadline = r.Context().Deadline()
+		got = time.Until(dl)
+	}))
+	for header, want := range map[string]time.Duration{
+		"":     time.Second,     // Default
+		"junk": time.Second,     // malformed counts as absent
+		"500m": 500 * time.Millisecond,
+		"10S":  2 * time.Second, // capped by Max
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			r.Header.Set(Header, header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if !hasDeadline || got > want || got < want-100*time.Millisecond {
+			t.Errorf("%s %q: budget %v, want about %v", Header, header, got, want)
+		}
+	}
+}
+
+func TestMiddlewareSpentBudget(t *testing.T) {
+	h := Middleware(Policy{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("spent budget reached the handler")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set(Header, "0n")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status %d, want 504", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type %q", ct)
+	}
+	var p struct {
+		Status   int    `json:"status"`
+		Instance string `json:"instance"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.Status != 504 || p.Instance != "/users" {
+		t.Errorf("body %s (%v)", rec.Body, err)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var header string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(Header)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := NewClient(0).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if req.Header.Get(Header) != "" {
+		t.Error("Transport modified the caller's request")
+	}
+	d, err := Decode(header)
+	if err != nil || d <= 0 || d > time.Second {
+		t.Errorf("server saw %s %q", Header, header)
+	}
+}
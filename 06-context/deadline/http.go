@@ -0,0 +1,88 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+// Policy is how much of a caller's budget a server accepts.
+type Policy struct {
+	// Default applies when the caller sent no deadline. Zero means no
+	// deadline at all.
+	Default time.Duration
+	// Max caps whatever the caller asked for. Zero means no cap.
+	Max time.Duration
+}
+
+// Middleware derives the request context's deadline from Header, bounded
+// by p. A request whose budget is already spent gets a problem+json 504
+// without reaching next. A malformed header is treated as absent.
+func Middleware(p Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			budget, ok := p.budget(r.Header.Get(Header))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if budget <= 0 {
+				apperr.NewProblem(r, http.StatusGatewayTimeout, "request deadline already exceeded").Write(w)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), budget)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func (p Policy) budget(header string) (time.Duration, bool) {
+	d, err := Decode(header)
+	if header == "" || err != nil {
+		if p.Default <= 0 {
+			return 0, false
+		}
+		d = p.Default
+	}
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+	return d, true
+}
+
+// Transport sets Header on outgoing requests from the request context's
+// deadline. The deadline itself is enforced by net/http through the same
+// context, so the header is purely information for the server.
+type Transport struct {
+	// Base defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	dl, ok := req.Context().Deadline()
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	remaining := time.Until(dl)
+	if remaining <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(Header, Encode(remaining))
+	return base.RoundTrip(req)
+}
+
+// NewClient returns a client whose requests carry their context deadline.
+// fallback is the overall timeout used when the context has none; zero
+// leaves it unbounded, which is rarely what you want.
+func NewClient(fallback time.Duration) *http.Client {
+	return &http.Client{Transport: &Transport{}, Timeout: fallback}
+}
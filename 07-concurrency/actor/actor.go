@@ -0,0 +1,134 @@
+// Package actor runs stateful components as actors: each one owns its
+// state, is driven by a single goroutine and is only reached through its
+// typed mailbox, so the state needs no locks.
+//
+// Actors live under a Supervisor, which restarts them with fresh state
+// when they fail (return an error or panic), either individually
+// (OneForOne) or all together (OneForAll) when children depend on each
+// other. The mailbox survives a restart, so senders holding a Ref don't
+// notice.
+//
+//	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{})
+//	room := actor.Spawn(sup, "room", func() actor.Behavior[RoomMsg] { return &Room{} })
+//	room.Send(ctx, Join{User: "ana"})
+//	n, err := actor.Ask(ctx, room, time.Second, func(r chan<- int) RoomMsg { return Count{r} })
+package actor
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrStop, returned from Receive, stops the actor for good instead of
+	// having it restarted.
+	ErrStop = errors.New("actor: stop")
+	// ErrStopped is returned when sending to an actor that has stopped for
+	// good, or whose supervisor has.
+	ErrStopped = errors.New("actor: stopped")
+	// ErrMailboxFull is returned by TrySend.
+	ErrMailboxFull = errors.New("actor: mailbox full")
+)
+
+// Behavior handles an actor's messages, one at a time. Returning an error
+// fails the actor; its supervisor decides what happens next.
+type Behavior[M any] interface {
+	Receive(ctx context.Context, msg M) error
+}
+
+// Starter is an optional Behavior hook run before the first message, on
+// every start and restart. An error fails the actor like Receive's would.
+type Starter interface {
+	Started(ctx context.Context) error
+}
+
+// Stopper is an optional Behavior hook run when the actor's goroutine
+// ends. err is why: nil when its supervisor stopped it, or the failure.
+type Stopper interface {
+	Stopped(err error)
+}
+
+// Option configures Spawn.
+type Option func(*config)
+
+type config struct {
+	mailbox int
+}
+
+// Mailbox sets how many messages can queue before Send blocks. The
+// default is 64.
+func Mailbox(n int) Option {
+	return func(c *config) { c.mailbox = max(n, 0) }
+}
+
+// Ref is the address of an actor. It's safe to share and stays valid
+// across restarts.
+type Ref[M any] struct {
+	name    string
+	mailbox chan M
+	dead    chan struct{} // closed when the actor stops for good
+}
+
+// Name is the name the actor was spawned with.
+func (r *Ref[M]) Name() string { return r.name }
+
+// Send queues msg, waiting while the mailbox is full until ctx is done.
+func (r *Ref[M]) Send(ctx context.Context, msg M) error {
+	select {
+	case <-r.dead:
+		return ErrStopped
+	default:
+	}
+	select {
+	case r.mailbox <- msg:
+		return nil
+	case <-r.dead:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySend queues msg only if there's room right now.
+func (r *Ref[M]) TrySend(msg M) error {
+	select {
+	case <-r.dead:
+		return ErrStopped
+	default:
+	}
+	select {
+	case r.mailbox <- msg:
+		return nil
+	default:
+		return ErrMailboxFull
+	}
+}
+
+// Done is closed once the actor has stopped for good.
+func (r *Ref[M]) Done() <-chan struct{} { return r.dead }
+
+// Ask sends the message build returns and waits for the actor to answer on
+// reply. timeout bounds the whole exchange, on top of ctx; zero leaves it
+// to ctx. The reply channel has room for one value, so an actor answering
+// after the asker gave up doesn't block.
+func Ask[M, R any](ctx context.Context, ref *Ref[M], timeout time.Duration, build func(reply chan<- R) M) (R, error) {
+	var zero R
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	reply := make(chan R, 1)
+	if err := ref.Send(ctx, build(reply)); err != nil {
+		return zero, err
+	}
+	select {
+	case v := <-reply:
+		return v, nil
+	case <-ref.dead:
+		return zero, ErrStopped
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
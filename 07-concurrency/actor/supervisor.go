@@ -0,0 +1,294 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// Strategy is how a supervisor reacts to a failed child.
+type Strategy int
+
+const (
+	// OneForOne restarts only the child that failed.
+	OneForOne Strategy = iota
+	// OneForAll stops every child and restarts them all, in spawn order.
+	// Use it when children share assumptions, e.g. a room and its
+	// broadcaster.
+	OneForAll
+)
+
+// ErrTooManyRestarts is the supervisor's error when its children failed
+// more often than SupervisorOptions allow. It wraps the last failure.
+var ErrTooManyRestarts = errors.New("actor: too many restarts")
+
+// SupervisorOptions configures NewSupervisor.
+type SupervisorOptions struct {
+	Strategy Strategy
+	// MaxRestarts within Within is the limit past which the supervisor
+	// gives up, stops every child and fails: something is wrong that
+	// restarting won't fix. Defaults are 3 restarts within 5s.
+	MaxRestarts int
+	Within      time.Duration
+	// OnRestart is called before each restart, e.g. to log err.
+	OnRestart func(name string, err error)
+	// StopTimeout bounds how long the supervisor waits for a child to
+	// return once its context is canceled, 5s if zero. A child stuck past
+	// it, e.g. in a Receive that ignores ctx, is abandoned: its goroutine
+	// is left behind and the supervisor carries on without it. If the
+	// goroutine ever comes unstuck it may still take messages off the
+	// mailbox until it notices it was canceled.
+	StopTimeout time.Duration
+	// OnStopTimeout is called for each abandoned child. Without it the
+	// child's name is printed to stderr.
+	OnStopTimeout func(name string)
+}
+
+// child is the part of an actor the supervisor handles without knowing
+// its message type.
+type child interface {
+	name() string
+	start(ctx context.Context, exits chan<- exit) *instance
+	kill()
+}
+
+// instance is one run of a child, between a start and its exit.
+type instance struct {
+	c      child
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type exit struct {
+	inst *instance
+	err  error
+}
+
+// Supervisor owns a set of actors. All child lifecycle changes happen on
+// its own goroutine.
+type Supervisor struct {
+	opts SupervisorOptions
+	ctx  context.Context
+
+	add      chan child
+	exits    chan exit
+	done     chan struct{}
+	err      error
+	restarts []time.Time
+
+	children []child
+	running  map[child]*instance
+}
+
+// NewSupervisor starts a supervisor that runs until ctx ends or its
+// restart limit is hit.
+func NewSupervisor(ctx context.Context, opts SupervisorOptions) *Supervisor {
+	if opts.MaxRestarts <= 0 {
+		opts.MaxRestarts = 3
+	}
+	if opts.Within <= 0 {
+		opts.Within = 5 * time.Second
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 5 * time.Second
+	}
+	s := &Supervisor{
+		opts:    opts,
+		ctx:     ctx,
+		add:     make(chan child),
+		exits:   make(chan exit),
+		done:    make(chan struct{}),
+		running: make(map[child]*instance),
+	}
+	go s.loop()
+	return s
+}
+
+// Done is closed once the supervisor and all its children have stopped.
+func (s *Supervisor) Done() <-chan struct{} { return s.done }
+
+// Err is nil while running, after a clean stop, or an
+// ErrTooManyRestarts.
+func (s *Supervisor) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Spawn starts an actor under s. newBehavior is called on every start, so
+// a restarted actor begins from fresh state.
+func Spawn[M any](s *Supervisor, name string, newBehavior func() Behavior[M], opts ...Option) *Ref[M] {
+	c := config{mailbox: 64}
+	for _, o := range opts {
+		o(&c)
+	}
+	a := &actor[M]{
+		ref: &Ref[M]{
+			name:    name,
+			mailbox: make(chan M, c.mailbox),
+			dead:    make(chan struct{}),
+		},
+		newBehavior: newBehavior,
+	}
+	select {
+	case s.add <- a:
+	case <-s.done:
+		a.kill()
+	}
+	return a.ref
+}
+
+func (s *Supervisor) loop() {
+	defer close(s.done)
+	for {
+		select {
+		case c := <-s.add:
+			s.children = append(s.children, c)
+			s.running[c] = c.start(s.ctx, s.exits)
+		case e := <-s.exits:
+			if s.running[e.inst.c] != e.inst {
+				continue // an instance we stopped ourselves
+			}
+			e.inst.cancel()
+			if e.err == nil || errors.Is(e.err, ErrStop) {
+				delete(s.running, e.inst.c)
+				e.inst.c.kill()
+				continue
+			}
+			if !s.allowRestart() {
+				s.err = fmt.Errorf("%w: %s: %w", ErrTooManyRestarts, e.inst.c.name(), e.err)
+				s.stopAll()
+				return
+			}
+			s.restart(e.inst.c, e.err)
+		case <-s.ctx.Done():
+			s.stopAll()
+			return
+		}
+	}
+}
+
+func (s *Supervisor) allowRestart() bool {
+	now := time.Now()
+	keep := s.restarts[:0]
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.opts.Within {
+			keep = append(keep, t)
+		}
+	}
+	s.restarts = append(keep, now)
+	return len(s.restarts) <= s.opts.MaxRestarts
+}
+
+func (s *Supervisor) restart(failed child, err error) {
+	if s.opts.OnRestart != nil {
+		s.opts.OnRestart(failed.name(), err)
+	}
+	if s.opts.Strategy == OneForOne {
+		s.running[failed] = failed.start(s.ctx, s.exits)
+		return
+	}
+	// stop the others, newest first, then bring everyone back in order
+	for i := len(s.children) - 1; i >= 0; i-- {
+		if inst, ok := s.running[s.children[i]]; ok && s.children[i] != failed {
+			s.stop(inst)
+		}
+	}
+	for _, c := range s.children {
+		if _, ok := s.running[c]; ok {
+			s.running[c] = c.start(s.ctx, s.exits)
+		}
+	}
+}
+
+func (s *Supervisor) stopAll() {
+	for i := len(s.children) - 1; i >= 0; i-- {
+		c := s.children[i]
+		if inst, ok := s.running[c]; ok {
+			s.stop(inst)
+			delete(s.running, c)
+		}
+		c.kill()
+	}
+}
+
+// stop cancels inst and waits for it to return, at most StopTimeout. An
+// instance that outlives the wait is reported and forgotten; when it does
+// return, its exit is ignored like that of any instance we stopped.
+func (s *Supervisor) stop(inst *instance) {
+	inst.cancel()
+	t := time.NewTimer(s.opts.StopTimeout)
+	defer t.Stop()
+	select {
+	case <-inst.done:
+	case <-t.C:
+		if s.opts.OnStopTimeout != nil {
+			s.opts.OnStopTimeout(inst.c.name())
+		} else {
+			fmt.Fprintf(os.Stderr, "actor: %s did not stop within %v, abandoning it\n", inst.c.name(), s.opts.StopTimeout)
+		}
+	}
+}
+
+type actor[M any] struct {
+	ref         *Ref[M]
+	newBehavior func() Behavior[M]
+}
+
+func (a *actor[M]) name() string { return a.ref.name }
+
+func (a *actor[M]) kill() {
+	select {
+	case <-a.ref.dead:
+	default:
+		close(a.ref.dead)
+	}
+}
+
+func (a *actor[M]) start(parent context.Context, exits chan<- exit) *instance {
+	ctx, cancel := context.WithCancel(parent)
+	inst := &instance{c: a, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		err := a.run(ctx)
+		close(inst.done)
+		// if we were canceled the supervisor isn't waiting for this
+		select {
+		case exits <- exit{inst, err}:
+		case <-ctx.Done():
+		}
+	}()
+	return inst
+}
+
+// run processes messages until ctx ends (nil) or the behavior fails.
+func (a *actor[M]) run(ctx context.Context) error {
+	b := a.newBehavior()
+	err := safego.Run(ctx, func(ctx context.Context) error {
+		if st, ok := b.(Starter); ok {
+			if err := st.Started(ctx); err != nil {
+				return err
+			}
+		}
+		for {
+			select {
+			case msg := <-a.ref.mailbox:
+				if err := b.Receive(ctx, msg); err != nil {
+					return err
+				}
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	})
+	if st, ok := b.(Stopper); ok {
+		st.Stopped(err)
+	}
+	return err
+}
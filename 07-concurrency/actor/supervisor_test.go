@@ -0,0 +1,260 @@
+package actor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/actor"
+)
+
+var errBoom = errors.New("boom")
+
+// msg is what the test actors receive: fail makes Receive return errBoom,
+// otherwise reply gets how many messages this run has handled.
+type msg struct {
+	fail  bool
+	reply chan<- int
+}
+
+// counter reports its lifecycle to events, so tests can see starts, stops
+// and their order.
+type counter struct {
+	name   string
+	events chan<- string
+	n      int
+}
+
+func (c *counter) Started(ctx context.Context) error {
+	c.events <- "start " + c.name
+	return nil
+}
+
+func (c *counter) Stopped(err error) {
+	if err != nil {
+		c.events <- "fail " + c.name
+		return
+	}
+	c.events <- "stop " + c.name
+}
+
+func (c *counter) Receive(ctx context.Context, m msg) error {
+	if m.fail {
+		return errBoom
+	}
+	c.n++
+	if m.reply != nil {
+		m.reply <- c.n
+	}
+	return nil
+}
+
+func spawnCounter(sup *actor.Supervisor, name string, events chan<- string) *actor.Ref[msg] {
+	return actor.Spawn(sup, name, func() actor.Behavior[msg] { return &counter{name: name, events: events} })
+}
+
+func count(t *testing.T, ref *actor.Ref[msg]) int {
+	t.Helper()
+	n, err := actor.Ask(context.Background(), ref, time.Second, func(r chan<- int) msg { return msg{reply: r} })
+	if err != nil {
+		t.Fatalf("ask %s: %v", ref.Name(), err)
+	}
+	return n
+}
+
+// next collects n events, failing the test if they don't come.
+func next(t *testing.T, events <-chan string, n int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(2 * time.Second)
+	for len(got) < n {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-timeout:
+			t.Fatalf("got %q, waiting for %d events", got, n)
+		}
+	}
+	return got
+}
+
+func TestOneForOneRestartsWithFreshState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var restarts []string
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{
+		OnRestart: func(name string, err error) { restarts = append(restarts, fmt.Sprint(name, ": ", err)) },
+	})
+	events := make(chan string, 16)
+	a := spawnCounter(sup, "a", events)
+	b := spawnCounter(sup, "b", events)
+	next(t, events, 2)
+
+	count(t, a)
+	count(t, b)
+	a.Send(ctx, msg{fail: true})
+	if n := count(t, a); n != 1 {
+		t.Errorf("a kept its state across the restart: n = %d", n)
+	}
+	if n := count(t, b); n != 2 {
+		t.Errorf("b was disturbed by a's restart: n = %d", n)
+	}
+	if want := []string{"a: boom"}; !slices.Equal(restarts, want) {
+		t.Errorf("restarts %q, want %q", restarts, want)
+	}
+}
+
+func TestRestartIntensity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var restarts atomic.Int32
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{
+		MaxRestarts: 2,
+		Within:      time.Minute,
+		OnRestart:   func(string, error) { restarts.Add(1) },
+	})
+	events := make(chan string, 16)
+	a := spawnCounter(sup, "a", events)
+
+	for range 3 {
+		a.Send(ctx, msg{fail: true})
+	}
+	select {
+	case <-sup.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("supervisor kept restarting past MaxRestarts")
+	}
+	if err := sup.Err(); !errors.Is(err, actor.ErrTooManyRestarts) || !errors.Is(err, errBoom) {
+		t.Errorf("Err() = %v", err)
+	}
+	if n := restarts.Load(); n != 2 {
+		t.Errorf("%d restarts, want 2", n)
+	}
+	select {
+	case <-a.Done():
+	default:
+		t.Error("child still alive after its supervisor gave up")
+	}
+}
+
+func TestRestartIntensityWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{MaxRestarts: 1, Within: 20 * time.Millisecond})
+	events := make(chan string, 16)
+	a := spawnCounter(sup, "a", events)
+
+	// one failure per window never adds up to two
+	for range 3 {
+		a.Send(ctx, msg{fail: true})
+		count(t, a)
+		time.Sleep(40 * time.Millisecond)
+	}
+	if err := sup.Err(); err != nil {
+		t.Fatalf("supervisor failed: %v", err)
+	}
+}
+
+func TestOneForAllOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{Strategy: actor.OneForAll})
+	events := make(chan string, 16)
+	a := spawnCounter(sup, "a", events)
+	b := spawnCounter(sup, "b", events)
+	c := spawnCounter(sup, "c", events)
+	next(t, events, 3)
+	for _, ref := range []*actor.Ref[msg]{a, b, c} {
+		count(t, ref)
+	}
+
+	// the others stop newest first, then everyone starts again. starts run
+	// on the children's own goroutines, so only the set is fixed.
+	b.Send(ctx, msg{fail: true})
+	got := next(t, events, 6)
+	if want := []string{"fail b", "stop c", "stop a"}; !slices.Equal(got[:3], want) {
+		t.Errorf("stopped %q, want %q", got[:3], want)
+	}
+	starts := slices.Sorted(slices.Values(got[3:]))
+	if want := []string{"start a", "start b", "start c"}; !slices.Equal(starts, want) {
+		t.Errorf("started %q, want %q", starts, want)
+	}
+	for _, ref := range []*actor.Ref[msg]{a, b, c} {
+		if n := count(t, ref); n != 1 {
+			t.Errorf("%s kept its state: n = %d", ref.Name(), n)
+		}
+	}
+}
+
+func TestAskAfterStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{})
+	events := make(chan string, 16)
+	a := spawnCounter(sup, "a", events)
+	count(t, a)
+
+	cancel()
+	<-sup.Done()
+	<-a.Done()
+	_, err := actor.Ask(context.Background(), a, time.Second, func(r chan<- int) msg { return msg{reply: r} })
+	if !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("Ask after stop: %v", err)
+	}
+	if err := a.TrySend(msg{}); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("TrySend after stop: %v", err)
+	}
+
+	// spawning on a stopped supervisor hands back a dead Ref
+	b := spawnCounter(sup, "b", events)
+	if _, err := actor.Ask(context.Background(), b, time.Second, func(r chan<- int) msg { return msg{reply: r} }); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("Ask on a late spawn: %v", err)
+	}
+}
+
+// stuck ignores ctx in Receive until release is closed.
+type stuck struct {
+	entered chan<- struct{}
+	release <-chan struct{}
+}
+
+func (s stuck) Receive(ctx context.Context, _ struct{}) error {
+	s.entered <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestStopTimeoutAbandonsChild(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	abandoned := make(chan string, 1)
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{
+		StopTimeout:   20 * time.Millisecond,
+		OnStopTimeout: func(name string) { abandoned <- name },
+	})
+	ref := actor.Spawn(sup, "stuck", func() actor.Behavior[struct{}] { return stuck{entered, release} })
+	ref.Send(ctx, struct{}{})
+	<-entered
+
+	cancel()
+	select {
+	case <-sup.Done():
+	case <-time.After(time.Second):
+		t.Fatal("supervisor waited on a stuck child")
+	}
+	select {
+	case name := <-abandoned:
+		if name != "stuck" {
+			t.Errorf("abandoned %q", name)
+		}
+	default:
+		t.Error("OnStopTimeout wasn't called")
+	}
+	if err := ref.Send(context.Background(), struct{}{}); !errors.Is(err, actor.ErrStopped) {
+		t.Errorf("Send to an abandoned child: %v", err)
+	}
+}
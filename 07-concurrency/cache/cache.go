@@ -0,0 +1,356 @@
+// Package cache is an in-memory key/value cache with per-entry TTL, a size
+// bound with LRU or LFU eviction, an eviction callback and hit/miss stats.
+// It's the generic, race-free version of MidCache in 07-concurrency.
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+)
+
+// ErrNotFound is returned by Get for keys that are missing or expired.
+var ErrNotFound = errors.New("cache: key not found")
+
+// Policy picks the entry to evict when the cache is full.
+type Policy int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU Policy = iota
+	// LFU evicts the least frequently used entry, the least recently used
+	// one among equals.
+	LFU
+)
+
+// Reason says why an entry left the cache.
+type Reason int
+
+const (
+	// Expired entries outlived their TTL.
+	Expired Reason = iota
+	// Evicted entries made room for new ones.
+	Evicted
+	// Deleted entries were removed with Delete or Clear.
+	Deleted
+	// Replaced entries were overwritten by Set.
+	Replaced
+)
+
+func (r Reason) String() string {
+	switch r {
+	case Expired:
+		return "expired"
+	case Evicted:
+		return "evicted"
+	case Deleted:
+		return "deleted"
+	case Replaced:
+		return "replaced"
+	}
+	return "unknown"
+}
+
+// Options configures a Cache. The zero value is an unbounded cache whose
+// entries never expire.
+type Options[K comparable, V any] struct {
+	// MaxEntries bounds the cache; 0 means unbounded.
+	MaxEntries int
+	// Policy is the eviction policy used when MaxEntries is reached.
+	Policy Policy
+	// TTL is the default lifetime for Set; 0 means entries don't expire
+	// and a negative TTL means they are expired on arrival (see
+	// SetWithTTL).
+	TTL time.Duration
+	// CleanupInterval starts a goroutine that drops expired entries every
+	// interval. Without it expired entries are only dropped when touched or
+	// when space is needed, in which case all of them go before a live
+	// entry is evicted. Stop it with Close.
+	CleanupInterval time.Duration
+	// OnEvict is called for every entry that leaves the cache, outside the
+	// lock, so it may use the cache.
+	OnEvict func(key K, value V, reason Reason)
+	// Clock defaults to the system clock; tests pass a *clock.Fake to
+	// expire entries without sleeping.
+	Clock clock.Clock
+}
+
+// Stats are counters since the cache was created.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Expired   uint64
+}
+
+// HitRatio is Hits / (Hits + Misses), 0 before the first lookup.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time // zero means never
+	// bookkeeping for the eviction policy
+	freq  uint64
+	tick  uint64
+	index int
+	prev  *entry[K, V]
+	next  *entry[K, V]
+}
+
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+type evicted[K comparable, V any] struct {
+	key    K
+	value  V
+	reason Reason
+}
+
+// Cache is safe for concurrent use. Every lookup updates recency or
+// frequency, so reads take the same lock as writes; see 07-concurrency's
+// shardmap when that lock becomes the bottleneck.
+type Cache[K comparable, V any] struct {
+	opts  Options[K, V]
+	clock clock.Clock
+
+	mu     sync.Mutex
+	items  map[K]*entry[K, V]
+	policy policy[K, V]
+	// nextExpiry is at or before the earliest expiry in items, zero when
+	// nothing expires, so a full cache only sweeps when something may
+	// actually have expired
+	nextExpiry time.Time
+
+	hits, misses, evictions, expired atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns an empty cache.
+func New[K comparable, V any](opts Options[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		opts:  opts,
+		clock: clock.Or(opts.Clock),
+		items: make(map[K]*entry[K, V]),
+		stop:  make(chan struct{}),
+	}
+	if opts.Policy == LFU {
+		c.policy = &lfu[K, V]{}
+	} else {
+		c.policy = &lru[K, V]{}
+	}
+	if opts.CleanupInterval > 0 {
+		go c.janitor(opts.CleanupInterval)
+	}
+	return c
+}
+
+// Get returns the value for key, or ErrNotFound.
+func (c *Cache[K, V]) Get(key K) (V, error) {
+	var zero V
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		c.misses.Add(1)
+		return zero, ErrNotFound
+	}
+	if e.expired(c.clock.Now()) {
+		c.removeLocked(e)
+		c.mu.Unlock()
+		c.misses.Add(1)
+		c.expired.Add(1)
+		c.notify([]evicted[K, V]{{e.key, e.value, Expired}})
+		return zero, ErrNotFound
+	}
+	c.policy.touch(e)
+	v := e.value
+	c.mu.Unlock()
+	c.hits.Add(1)
+	return v, nil
+}
+
+// Peek is Get without counting a hit or miss or touching recency.
+func (c *Cache[K, V]) Peek(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok || e.expired(c.clock.Now()) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value with the default TTL.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.opts.TTL)
+}
+
+// SetWithTTL stores value for ttl; 0 means it doesn't expire. A negative
+// ttl means the value is already expired: nothing is stored and an
+// existing entry for key is deleted.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	if ttl < 0 {
+		c.Delete(key)
+		return
+	}
+	now := c.clock.Now()
+	var expires time.Time
+	if ttl > 0 {
+		expires = now.Add(ttl)
+	}
+
+	var out []evicted[K, V]
+	c.mu.Lock()
+	if !expires.IsZero() && (c.nextExpiry.IsZero() || expires.Before(c.nextExpiry)) {
+		c.nextExpiry = expires
+	}
+	if e, ok := c.items[key]; ok {
+		out = append(out, evicted[K, V]{e.key, e.value, Replaced})
+		e.value, e.expires = value, expires
+		c.policy.touch(e)
+		c.mu.Unlock()
+		c.notify(out)
+		return
+	}
+	if c.opts.MaxEntries > 0 && len(c.items) >= c.opts.MaxEntries {
+		out = c.makeRoomLocked(now)
+	}
+	e := &entry[K, V]{key: key, value: value, expires: expires}
+	c.items[key] = e
+	c.policy.add(e)
+	c.mu.Unlock()
+	c.notify(out)
+}
+
+// makeRoomLocked frees at least one slot: every expired entry if there
+// are any, otherwise the policy's victim.
+func (c *Cache[K, V]) makeRoomLocked(now time.Time) []evicted[K, V] {
+	if !c.nextExpiry.IsZero() && !now.Before(c.nextExpiry) {
+		if out := c.sweepLocked(now); len(out) > 0 {
+			c.expired.Add(uint64(len(out)))
+			return out
+		}
+	}
+	e := c.policy.victim()
+	c.removeLocked(e)
+	c.evictions.Add(1)
+	return []evicted[K, V]{{e.key, e.value, Evicted}}
+}
+
+// sweepLocked removes every expired entry and moves nextExpiry up to the
+// earliest expiry left.
+func (c *Cache[K, V]) sweepLocked(now time.Time) []evicted[K, V] {
+	var out []evicted[K, V]
+	c.nextExpiry = time.Time{}
+	for _, e := range c.items {
+		switch {
+		case e.expired(now):
+			c.removeLocked(e)
+			out = append(out, evicted[K, V]{e.key, e.value, Expired})
+		case !e.expires.IsZero() && (c.nextExpiry.IsZero() || e.expires.Before(c.nextExpiry)):
+			c.nextExpiry = e.expires
+		}
+	}
+	return out
+}
+
+// Delete removes key and reports whether it was there.
+func (c *Cache[K, V]) Delete(key K) bool {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	if ok {
+		c.removeLocked(e)
+	}
+	c.mu.Unlock()
+	if ok {
+		c.notify([]evicted[K, V]{{e.key, e.value, Deleted}})
+	}
+	return ok
+}
+
+// Clear removes every entry.
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	out := make([]evicted[K, V], 0, len(c.items))
+	for _, e := range c.items {
+		out = append(out, evicted[K, V]{e.key, e.value, Deleted})
+	}
+	c.items = make(map[K]*entry[K, V])
+	c.policy.reset()
+	c.nextExpiry = time.Time{}
+	c.mu.Unlock()
+	c.notify(out)
+}
+
+// Len is the number of entries, expired ones not yet dropped included.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Stats returns the counters.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Expired:   c.expired.Load(),
+	}
+}
+
+// DeleteExpired drops every expired entry now.
+func (c *Cache[K, V]) DeleteExpired() {
+	now := c.clock.Now()
+	c.mu.Lock()
+	out := c.sweepLocked(now)
+	c.mu.Unlock()
+	c.expired.Add(uint64(len(out)))
+	c.notify(out)
+}
+
+// Close stops the cleanup goroutine, if any. The cache stays usable.
+func (c *Cache[K, V]) Close() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+func (c *Cache[K, V]) janitor(every time.Duration) {
+	t := c.clock.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+			c.DeleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Cache[K, V]) removeLocked(e *entry[K, V]) {
+	delete(c.items, e.key)
+	c.policy.remove(e)
+}
+
+func (c *Cache[K, V]) notify(out []evicted[K, V]) {
+	if c.opts.OnEvict == nil {
+		return
+	}
+	for _, ev := range out {
+		c.opts.OnEvict(ev.key, ev.value, ev.reason)
+	}
+}
@@ -0,0 +1,164 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/cache"
+	"github.com/midsane/go-playground/07-concurrency/clock"
+)
+
+// gone records what OnEvict saw, as "key:reason".
+type gone []string
+
+func (g *gone) onEvict(k string, _ int, r cache.Reason) {
+	*g = append(*g, fmt.Sprintf("%s:%s", k, r))
+}
+
+func newCache(opts cache.Options[string, int]) (*cache.Cache[string, int], *clock.Fake, *gone) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := &gone{}
+	opts.Clock, opts.OnEvict = f, g.onEvict
+	return cache.New(opts), f, g
+}
+
+func setAll(c *cache.Cache[string, int], keys ...string) {
+	for i, k := range keys {
+		c.Set(k, i)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c, f, g := newCache(cache.Options[string, int]{TTL: time.Minute})
+	c.Set("a", 1)
+	c.SetWithTTL("forever", 2, 0)
+
+	f.Advance(time.Minute - time.Nanosecond)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Fatalf("Get before expiry = %d, %v", v, err)
+	}
+	f.Advance(time.Nanosecond)
+	if _, err := c.Get("a"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("Get at expiry = %v", err)
+	}
+	f.Advance(time.Hour)
+	if _, err := c.Get("forever"); err != nil {
+		t.Errorf("TTL 0 entry expired: %v", err)
+	}
+
+	c.SetWithTTL("forever", 3, -1)
+	if _, ok := c.Peek("forever"); ok {
+		t.Error("negative TTL left the entry in place")
+	}
+	if want := []string{"a:expired", "forever:deleted"}; !slices.Equal(*g, want) {
+		t.Errorf("OnEvict saw %v, want %v", *g, want)
+	}
+	if s := c.Stats(); s.Hits != 2 || s.Misses != 1 || s.Expired != 1 || s.Evictions != 0 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestDeleteExpiredAndJanitor(t *testing.T) {
+	c, f, _ := newCache(cache.Options[string, int]{TTL: time.Minute, CleanupInterval: time.Minute})
+	defer c.Close()
+	setAll(c, "a", "b")
+	c.SetWithTTL("c", 0, time.Hour)
+
+	f.BlockUntil(1) // the janitor's ticker
+	f.Advance(time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d entries", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if s := c.Stats(); s.Expired != 2 {
+		t.Errorf("Expired = %d", s.Expired)
+	}
+
+	f.Advance(time.Hour)
+	c.DeleteExpired()
+	if c.Len() != 0 {
+		t.Errorf("DeleteExpired left %d entries", c.Len())
+	}
+}
+
+func TestLRUEvictionOrder(t *testing.T) {
+	c, _, g := newCache(cache.Options[string, int]{MaxEntries: 3})
+	setAll(c, "a", "b", "c")
+	c.Get("a")
+	c.Peek("b") // doesn't count as a use
+	c.Set("d", 0)
+	c.Set("c", 1) // replacing is a use
+	c.Set("e", 0)
+	c.Set("f", 0)
+
+	want := []string{"b:evicted", "c:replaced", "a:evicted", "d:evicted"}
+	if !slices.Equal(*g, want) {
+		t.Errorf("OnEvict saw %v, want %v", *g, want)
+	}
+	if s := c.Stats(); s.Evictions != 3 || s.Hits != 1 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestLFUEvictionOrder(t *testing.T) {
+	c, _, g := newCache(cache.Options[string, int]{MaxEntries: 3, Policy: cache.LFU})
+	setAll(c, "a", "b", "c")
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	c.Set("d", 0) // b is used least
+	c.Set("e", 0) // d has one use, c two
+	c.Get("e")
+	c.Set("f", 0) // c and e tie on 2 uses: c was used longer ago
+
+	want := []string{"b:evicted", "d:evicted", "c:evicted"}
+	if !slices.Equal(*g, want) {
+		t.Errorf("OnEvict saw %v, want %v", *g, want)
+	}
+	if s := c.Stats(); s.Evictions != 3 || s.Hits != 4 || s.Misses != 0 {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestFullCacheDropsExpiredBeforeEvicting(t *testing.T) {
+	c, f, g := newCache(cache.Options[string, int]{MaxEntries: 2})
+	c.SetWithTTL("short", 0, time.Second)
+	c.Set("live", 0)
+	c.Get("short") // most recently used, but expired by the next Set
+
+	f.Advance(time.Second)
+	c.Set("new", 0)
+	if want := []string{"short:expired"}; !slices.Equal(*g, want) {
+		t.Errorf("OnEvict saw %v, want %v", *g, want)
+	}
+	if _, ok := c.Peek("live"); !ok {
+		t.Error("live entry evicted while an expired one was there")
+	}
+}
+
+func TestStats(t *testing.T) {
+	c, _, g := newCache(cache.Options[string, int]{})
+	if r := c.Stats().HitRatio(); r != 0 {
+		t.Errorf("HitRatio before lookups = %v", r)
+	}
+	c.Set("a", 1)
+	c.Get("a")
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+	if s := c.Stats(); s.Hits != 3 || s.Misses != 1 || s.HitRatio() != 0.75 {
+		t.Errorf("Stats = %+v, ratio %v", s, s.HitRatio())
+	}
+
+	c.Set("b", 2)
+	c.Clear()
+	if c.Len() != 0 || len(*g) != 2 {
+		t.Errorf("Clear: %d left, OnEvict saw %v", c.Len(), *g)
+	}
+}
@@ -0,0 +1,115 @@
+package cache
+
+import "container/heap"
+
+// policy tracks entries for eviction. Every method is called with the
+// cache lock held.
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])
+	touch(e *entry[K, V])
+	remove(e *entry[K, V])
+	// victim returns the entry to evict; the cache is never empty here.
+	victim() *entry[K, V]
+	reset()
+}
+
+// lru is a doubly linked list threaded through the entries, most recently
+// used at the front.
+type lru[K comparable, V any] struct {
+	head, tail *entry[K, V]
+}
+
+func (l *lru[K, V]) add(e *entry[K, V]) {
+	e.prev, e.next = nil, l.head
+	if l.head != nil {
+		l.head.prev = e
+	}
+	l.head = e
+	if l.tail == nil {
+		l.tail = e
+	}
+}
+
+func (l *lru[K, V]) touch(e *entry[K, V]) {
+	if l.head == e {
+		return
+	}
+	l.remove(e)
+	l.add(e)
+}
+
+func (l *lru[K, V]) remove(e *entry[K, V]) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		l.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		l.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+func (l *lru[K, V]) victim() *entry[K, V] { return l.tail }
+
+func (l *lru[K, V]) reset() { l.head, l.tail = nil, nil }
+
+// lfu is a min-heap on (frequency, last use), so ties go to the least
+// recently used entry.
+type lfu[K comparable, V any] struct {
+	h    lfuHeap[K, V]
+	tick uint64
+}
+
+func (l *lfu[K, V]) add(e *entry[K, V]) {
+	l.tick++
+	e.freq, e.tick = 1, l.tick
+	heap.Push(&l.h, e)
+}
+
+func (l *lfu[K, V]) touch(e *entry[K, V]) {
+	l.tick++
+	e.freq++
+	e.tick = l.tick
+	heap.Fix(&l.h, e.index)
+}
+
+func (l *lfu[K, V]) remove(e *entry[K, V]) { heap.Remove(&l.h, e.index) }
+
+func (l *lfu[K, V]) victim() *entry[K, V] { return l.h[0] }
+
+func (l *lfu[K, V]) reset() { l.h = nil }
+
+type lfuHeap[K comparable, V any] []*entry[K, V]
+
+func (h lfuHeap[K, V]) Len() int { return len(h) }
+
+func (h lfuHeap[K, V]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].tick < h[j].tick
+}
+
+func (h lfuHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap[K, V]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	e.index = -1
+	return e
+}
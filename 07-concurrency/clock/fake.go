@@ -0,0 +1,237 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only moves when told to. Timers, tickers, Sleep and
+// After all fire from Advance, in order of their due time (then creation
+// order), with Now set to each one's due time as it fires, so code that
+// reacts to one timer by starting another sees consistent times.
+//
+// Like the real ones since Go 1.23, a timer or ticker holds at most one
+// unreceived tick, a newer tick is dropped while it's there, and Stop and
+// Reset discard it, so no receive after they return sees a stale time.
+// AfterFunc callbacks run synchronously inside Advance; they must not call
+// Advance.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  timerHeap
+	seq     uint64
+	changed *sync.Cond // broadcast when timers are added
+}
+
+// NewFake returns a fake clock reading start.
+func NewFake(start time.Time) *Fake {
+	f := &Fake{now: start}
+	f.changed = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration { return f.Now().Sub(t) }
+
+// Sleep blocks until another goroutine advances the clock by d.
+func (f *Fake) Sleep(d time.Duration) { <-f.After(d) }
+
+func (f *Fake) After(d time.Duration) <-chan time.Time { return f.NewTimer(d).C() }
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0, nil)
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.add(d, 0, fn)
+}
+
+// NewTicker panics on a non-positive period, like time.NewTicker.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return (*fakeTicker)(f.add(d, d, nil))
+}
+
+func (f *Fake) add(d, period time.Duration, fn func()) *fakeTimer {
+	t := &fakeTimer{f: f, period: period, fn: fn, index: -1}
+	if fn == nil {
+		t.ch = make(chan time.Time, 1)
+	}
+	f.mu.Lock()
+	f.scheduleLocked(t, d)
+	f.mu.Unlock()
+	return t
+}
+
+func (f *Fake) scheduleLocked(t *fakeTimer, d time.Duration) {
+	f.seq++
+	t.when, t.seq = f.now.Add(d), f.seq
+	heap.Push(&f.timers, t)
+	f.changed.Broadcast()
+}
+
+// Advance moves the clock forward by d, firing everything that comes due
+// on the way.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	f.mu.Unlock()
+	f.advanceTo(end)
+}
+
+// Set moves the clock to t, firing everything due by then. Moving it
+// backwards only changes Now.
+func (f *Fake) Set(t time.Time) {
+	f.advanceTo(t)
+}
+
+func (f *Fake) advanceTo(end time.Time) {
+	for {
+		f.mu.Lock()
+		if len(f.timers) == 0 || f.timers[0].when.After(end) {
+			f.now = end
+			f.mu.Unlock()
+			return
+		}
+		t := heap.Pop(&f.timers).(*fakeTimer)
+		if t.when.After(f.now) {
+			f.now = t.when
+		}
+		if t.period > 0 {
+			f.seq++
+			t.when, t.seq = t.when.Add(t.period), f.seq
+			heap.Push(&f.timers, t)
+		}
+		if t.fn == nil {
+			// under mu, so a Stop or Reset can't miss it and leave it stale
+			select {
+			case t.ch <- f.now:
+			default:
+			}
+		}
+		f.mu.Unlock()
+
+		if t.fn != nil {
+			t.fn()
+		}
+	}
+}
+
+// Pending is the number of timers and tickers waiting to fire.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// BlockUntil waits until at least n timers are pending. Tests use it to
+// know that the code under test has reached its Sleep or select before
+// calling Advance.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.changed.Wait()
+	}
+}
+
+type fakeTimer struct {
+	f      *Fake
+	when   time.Time
+	seq    uint64
+	period time.Duration // > 0 for tickers
+	ch     chan time.Time
+	fn     func()
+	index  int // in the heap, -1 when not scheduled
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.drainLocked()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&t.f.timers, t.index)
+	return true
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	return t.reset(d, 0)
+}
+
+func (t *fakeTimer) reset(d, period time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.period = period
+	t.drainLocked()
+	active := t.index >= 0
+	if active {
+		heap.Remove(&t.f.timers, t.index)
+	}
+	t.f.scheduleLocked(t, d)
+	return active
+}
+
+// drainLocked drops a tick that was sent but not received.
+func (t *fakeTimer) drainLocked() {
+	select {
+	case <-t.ch:
+	default:
+	}
+}
+
+type fakeTicker fakeTimer
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() { (*fakeTimer)(t).Stop() }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	(*fakeTimer)(t).reset(d, d)
+}
+
+type timerHeap []*fakeTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if !h[i].when.Equal(h[j].when) {
+		return h[i].when.Before(h[j].when)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	t := x.(*fakeTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
@@ -0,0 +1,165 @@
+package clock_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestAdvanceFiresInOrder(t *testing.T) {
+	f := clock.NewFake(start)
+	var got []string
+	at := func(name string, d time.Duration) {
+		f.AfterFunc(d, func() {
+			if now := f.Now(); !now.Equal(start.Add(d)) {
+				t.Errorf("%s ran at %v, want %v", name, now, start.Add(d))
+			}
+			got = append(got, name)
+		})
+	}
+	at("c", 3*time.Second)
+	at("a", time.Second)
+	at("b1", 2*time.Second)
+	at("b2", 2*time.Second) // same due time: creation order
+
+	f.Advance(2 * time.Second)
+	if want := []string{"a", "b1", "b2"}; !slices.Equal(got, want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	f.Advance(time.Second)
+	if len(got) != 4 || got[3] != "c" {
+		t.Fatalf("fired %v", got)
+	}
+	if !f.Now().Equal(start.Add(3 * time.Second)) {
+		t.Errorf("Now = %v", f.Now())
+	}
+}
+
+func TestTimerStopAndReset(t *testing.T) {
+	f := clock.NewFake(start)
+	tm := f.NewTimer(time.Second)
+	if !tm.Stop() {
+		t.Error("Stop on an active timer = false")
+	}
+	if tm.Stop() {
+		t.Error("second Stop = true")
+	}
+	if tm.Reset(time.Second) {
+		t.Error("Reset on a stopped timer = true")
+	}
+	if !tm.Reset(2 * time.Second) {
+		t.Error("Reset on an active timer = false")
+	}
+
+	f.Advance(2 * time.Second)
+	if tm.Stop() {
+		t.Error("Stop on a fired timer = true")
+	}
+	// the tick that fired but wasn't received is gone after Stop
+	if _, ok := received(tm.C()); ok {
+		t.Error("stale tick received after Stop")
+	}
+
+	tm.Reset(time.Second)
+	f.Advance(time.Second)
+	if tm.Reset(time.Second) {
+		t.Error("Reset on a fired timer = true")
+	}
+	if _, ok := received(tm.C()); ok {
+		t.Error("stale tick received after Reset")
+	}
+	f.Advance(time.Second)
+	if got, ok := received(tm.C()); !ok || !got.Equal(start.Add(4*time.Second)) {
+		t.Errorf("tick = %v, %v", got, ok)
+	}
+}
+
+func TestTickerRearms(t *testing.T) {
+	f := clock.NewFake(start)
+	tk := f.NewTicker(time.Second)
+
+	f.Advance(time.Second)
+	if got, ok := received(tk.C()); !ok || !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("first tick = %v, %v", got, ok)
+	}
+	// three periods unreceived: the first stays, the rest are dropped
+	f.Advance(3 * time.Second)
+	if got, ok := received(tk.C()); !ok || !got.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("tick = %v, %v", got, ok)
+	}
+	if _, ok := received(tk.C()); ok {
+		t.Fatal("more than one tick buffered")
+	}
+	f.Advance(time.Second)
+	if got, ok := received(tk.C()); !ok || !got.Equal(start.Add(5*time.Second)) {
+		t.Fatalf("tick after catching up = %v, %v", got, ok)
+	}
+
+	tk.Reset(2 * time.Second)
+	f.Advance(time.Second)
+	if _, ok := received(tk.C()); ok {
+		t.Fatal("ticked on the old period after Reset")
+	}
+	f.Advance(time.Second)
+	if _, ok := received(tk.C()); !ok {
+		t.Fatal("no tick on the new period")
+	}
+
+	tk.Stop()
+	f.Advance(time.Hour)
+	if _, ok := received(tk.C()); ok || f.Pending() != 0 {
+		t.Errorf("ticker still running after Stop, %d pending", f.Pending())
+	}
+}
+
+func TestAfterFunc(t *testing.T) {
+	f := clock.NewFake(start)
+	ran := 0
+	tm := f.AfterFunc(time.Second, func() { ran++ })
+	if tm.C() != nil {
+		t.Error("AfterFunc timer has a channel")
+	}
+	stopped := f.AfterFunc(time.Second, func() { t.Error("stopped func ran") })
+	stopped.Stop()
+
+	f.Advance(time.Second)
+	if ran != 1 {
+		t.Fatalf("ran %d times", ran)
+	}
+	tm.Reset(time.Second)
+	f.Advance(time.Second)
+	if ran != 2 {
+		t.Errorf("ran %d times after Reset", ran)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	f := clock.NewFake(start)
+	done := make(chan struct{})
+	go func() {
+		f.Sleep(5 * time.Second)
+		close(done)
+	}()
+	f.BlockUntil(1)
+	f.Advance(4 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("Sleep returned early")
+	default:
+	}
+	f.Advance(time.Second)
+	<-done
+}
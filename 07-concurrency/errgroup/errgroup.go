@@ -0,0 +1,133 @@
+// Package errgroup runs a set of related goroutines that share a context:
+// the first failure cancels the rest, and Wait returns every error except
+// the context.Canceled that cancellation itself causes.
+//
+// It differs from safego.Group in the cancellation and the optional limit
+// on how many run at once; panics are recovered the same way.
+package errgroup
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/midsane/go-playground/07-concurrency/safego"
+	"github.com/midsane/go-playground/07-concurrency/semaphore"
+)
+
+// Group must be created with New.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	sem    *semaphore.Weighted
+
+	mu   sync.Mutex
+	errs []error
+	// selfCanceled is set once a failure, not the parent context, has
+	// canceled ctx
+	selfCanceled bool
+}
+
+// New returns a group whose goroutines get a context derived from ctx,
+// canceled when any of them fails or Wait returns.
+func New(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// SetLimit caps how many goroutines run at once; n <= 0 removes the cap.
+// It must be called before the first Go.
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = semaphore.NewWeighted(int64(n))
+}
+
+// Go runs fn in a new goroutine, first waiting for a free slot if there's a
+// limit. Once the group's context is done, Go doesn't start fn at all.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	if g.sem != nil {
+		if err := g.sem.Acquire(g.ctx, 1); err != nil {
+			g.skip(err)
+			return
+		}
+	} else if err := g.ctx.Err(); err != nil {
+		g.skip(err)
+		return
+	}
+	g.start(fn)
+}
+
+// TryGo is Go without waiting: it reports false, and doesn't run fn, if
+// the limit is reached or the group is already canceled.
+func (g *Group) TryGo(fn func(ctx context.Context) error) bool {
+	if g.ctx.Err() != nil {
+		return false
+	}
+	if g.sem != nil && !g.sem.TryAcquire(1) {
+		return false
+	}
+	g.start(fn)
+	return true
+}
+
+func (g *Group) start(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer g.sem.Release(1)
+		}
+		if err := safego.Run(g.ctx, fn); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// a goroutine giving up because the group canceled it after an earlier
+	// failure isn't news. a cancellation from the parent context is kept,
+	// the group didn't cause it.
+	if g.selfCanceled && errors.Is(err, context.Canceled) {
+		return
+	}
+	g.errs = append(g.errs, err)
+	if g.ctx.Err() == nil {
+		g.selfCanceled = true
+		g.cancel(err)
+	}
+}
+
+// skip records why fn wasn't started, once: after the first failure the
+// rest are just fallout.
+func (g *Group) skip(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.errs) == 0 {
+		g.errs = append(g.errs, err)
+	}
+}
+
+// Wait blocks until every started goroutine has returned, then returns
+// their errors: nil, the only error, or all of them joined in the order
+// they happened. The one thing left out is context.Canceled returned after
+// the group canceled its context because of a failure; everything else,
+// cancellations of the parent context included, is kept.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(nil)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch len(g.errs) {
+	case 0:
+		return nil
+	case 1:
+		return g.errs[0]
+	}
+	return errors.Join(g.errs...)
+}
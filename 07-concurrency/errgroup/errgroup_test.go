@@ -0,0 +1,128 @@
+package errgroup_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/errgroup"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// waitForCancel is a well-behaved worker: it stops when the group does.
+func waitForCancel(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFirstErrorCancelsAndHidesFallout(t *testing.T) {
+	boom := errors.New("boom")
+	var groupCtx context.Context
+	g := errgroup.New(context.Background())
+	for range 3 {
+		g.Go(waitForCancel)
+	}
+	g.Go(func(ctx context.Context) error {
+		groupCtx = ctx
+		return boom
+	})
+
+	// the three context.Canceled the failure caused are left out
+	if err := g.Wait(); err != boom {
+		t.Errorf("Wait = %v, want just boom", err)
+	}
+	if cause := context.Cause(groupCtx); cause != boom {
+		t.Errorf("Cause = %v, want boom", cause)
+	}
+}
+
+func TestKeepsOtherErrorsAfterFailure(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	g := errgroup.New(context.Background())
+	started := make(chan struct{})
+	g.Go(func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return second // not a cancellation, so it's news
+	})
+	<-started
+	g.Go(func(context.Context) error { return first })
+
+	err := g.Wait()
+	if !errors.Is(err, first) || !errors.Is(err, second) {
+		t.Errorf("Wait = %v, want both errors", err)
+	}
+}
+
+func TestParentCancellationIsKept(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	g := errgroup.New(parent)
+	g.Go(waitForCancel)
+	g.Go(waitForCancel)
+	cancel()
+	// nothing in the group failed: the cancellation came from outside
+	if err := g.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait = %v, want context.Canceled", err)
+	}
+}
+
+func TestGoAfterFailureDoesNotStart(t *testing.T) {
+	boom := errors.New("boom")
+	g := errgroup.New(context.Background())
+	g.Go(func(context.Context) error { return boom })
+	for g.TryGo(func(context.Context) error { return nil }) {
+		// until the failure has canceled the group
+		time.Sleep(time.Millisecond)
+	}
+	var ran atomic.Bool
+	g.Go(func(context.Context) error { ran.Store(true); return nil })
+	if err := g.Wait(); err != boom {
+		t.Errorf("Wait = %v", err)
+	}
+	if ran.Load() {
+		t.Error("Go started fn on a canceled group")
+	}
+}
+
+func TestPanicIsAnError(t *testing.T) {
+	g := errgroup.New(context.Background())
+	g.Go(func(context.Context) error { panic("oops") })
+	var perr *safego.PanicError
+	if err := g.Wait(); !errors.As(err, &perr) || perr.Value != "oops" {
+		t.Errorf("Wait = %v", err)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	g := errgroup.New(context.Background())
+	g.SetLimit(2)
+	var running, peak atomic.Int32
+	for range 10 {
+		g.Go(func(context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("%d running at once with a limit of 2", p)
+	}
+
+	g = errgroup.New(context.Background())
+	g.SetLimit(1)
+	release := make(chan struct{})
+	g.Go(func(context.Context) error { <-release; return nil })
+	if g.TryGo(func(context.Context) error { return nil }) {
+		t.Error("TryGo went past the limit")
+	}
+	close(release)
+	g.Wait()
+}
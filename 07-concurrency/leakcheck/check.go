@@ -0,0 +1,143 @@
+package leakcheck
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Option adjusts what Find and Check consider a leak.
+type Option func(*config)
+
+type config struct {
+	timeout time.Duration
+	ignore  []func(Goroutine) bool
+}
+
+// Timeout sets how long to wait for goroutines to finish on their own
+// before calling them leaked. The default is 1s.
+func Timeout(d time.Duration) Option {
+	return func(c *config) { c.timeout = d }
+}
+
+// IgnoreTopFunction allows goroutines currently blocked in fn, e.g. a
+// package-level worker that lives for the whole process.
+func IgnoreTopFunction(fn string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, func(g Goroutine) bool { return g.Top == fn })
+	}
+}
+
+// IgnoreCreatedBy allows goroutines started by fn.
+func IgnoreCreatedBy(fn string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, func(g Goroutine) bool { return g.CreatedBy == fn })
+	}
+}
+
+// Ignore allows any goroutine f returns true for.
+func Ignore(f func(Goroutine) bool) Option {
+	return func(c *config) { c.ignore = append(c.ignore, f) }
+}
+
+// runtimeOwned are goroutines the runtime or the standard library start on
+// their own and never stop.
+var runtimeOwned = []string{
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"runtime.ensureSigM",
+	"testing.(*T).Run",
+	"testing.tRunner.func1",
+	"testing.runTests",
+	"testing.(*M).startAlarm",
+}
+
+func (c *config) allowed(g Goroutine) bool {
+	for _, fn := range runtimeOwned {
+		if g.Top == fn || g.CreatedBy == fn {
+			return true
+		}
+	}
+	for _, f := range c.ignore {
+		if f(g) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is the set of goroutines alive at some point.
+type Snapshot map[int64]struct{}
+
+// Take records the goroutines alive now.
+func Take() Snapshot {
+	s := Snapshot{}
+	for _, g := range All() {
+		s[g.ID] = struct{}{}
+	}
+	return s
+}
+
+// Find returns goroutines that were started after before was taken, are
+// still running after the timeout and aren't allowed by opts. It polls, so
+// goroutines that are just winding down don't count.
+func Find(before Snapshot, opts ...Option) []Goroutine {
+	c := config{timeout: time.Second}
+	for _, o := range opts {
+		o(&c)
+	}
+	deadline := time.Now().Add(c.timeout)
+	for delay := time.Millisecond; ; delay = min(2*delay, 100*time.Millisecond) {
+		var leaked []Goroutine
+		self := currentID()
+		for _, g := range All() {
+			if _, ok := before[g.ID]; ok || g.ID == self || c.allowed(g) {
+				continue
+			}
+			leaked = append(leaked, g)
+		}
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(delay)
+	}
+}
+
+// TB is the part of testing.TB that Check needs.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Check snapshots the goroutines alive now and returns a func that fails
+// t if any new ones are still around when it runs. Use it with defer.
+func Check(t TB, opts ...Option) func() {
+	before := Take()
+	return func() {
+		t.Helper()
+		if leaked := Find(before, opts...); len(leaked) > 0 {
+			t.Errorf("%s", Report(leaked))
+		}
+	}
+}
+
+// Report formats leaked goroutines, one block per goroutine.
+func Report(leaked []Goroutine) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d leaked goroutine(s):", len(leaked))
+	for _, g := range leaked {
+		fmt.Fprintf(&b, "\n\ngoroutine %d [%s]:\n%s", g.ID, g.State, g.Stack)
+	}
+	return b.String()
+}
+
+func currentID() int64 {
+	var buf [64]byte
+	s := string(buf[:runtime.Stack(buf[:], false)])
+	s = strings.TrimPrefix(s, "goroutine ")
+	s, _, _ = strings.Cut(s, " ")
+	var id int64
+	fmt.Sscan(s, &id)
+	return id
+}
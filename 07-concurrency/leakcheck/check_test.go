@@ -0,0 +1,80 @@
+package leakcheck_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/leakcheck"
+	"github.com/midsane/go-playground/07-concurrency/pipeline"
+)
+
+// recorder is a leakcheck.TB that keeps failures instead of failing the
+// test, so a leak can be asserted on.
+type recorder struct{ errs []string }
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestPassesWhenPipelineIsDrained(t *testing.T) {
+	defer leakcheck.Check(t)()
+
+	ctx := context.Background()
+	sum := 0
+	for v := range pipeline.Merge(ctx, pipeline.From(ctx, 1, 2, 3), pipeline.From(ctx, 4, 5)) {
+		sum += v
+	}
+	if sum != 15 {
+		t.Fatalf("sum = %d", sum)
+	}
+}
+
+func TestPassesWhenPipelineIsCancelled(t *testing.T) {
+	defer leakcheck.Check(t)()
+
+	// the consumer stops early, but cancelling ctx lets every stage return
+	ctx, cancel := context.WithCancel(context.Background())
+	out := pipeline.Merge(ctx, pipeline.From(ctx, 1, 2, 3), pipeline.From(ctx, 4, 5, 6))
+	<-out
+	cancel()
+}
+
+func TestCatchesAbandonedPipeline(t *testing.T) {
+	rec := &recorder{}
+	check := leakcheck.Check(rec, leakcheck.Timeout(100*time.Millisecond))
+
+	// read one value and walk away without cancelling: the From and Merge
+	// goroutines stay blocked on their sends forever
+	ctx, cancel := context.WithCancel(context.Background())
+	out := pipeline.Merge(ctx, pipeline.From(ctx, 1, 2, 3), pipeline.From(ctx, 4, 5, 6))
+	<-out
+	check()
+	cancel() // clean up for the tests that follow
+
+	if len(rec.errs) != 1 {
+		t.Fatalf("want one failure, got %d", len(rec.errs))
+	}
+	if !strings.Contains(rec.errs[0], "leaked goroutine") || !strings.Contains(rec.errs[0], "pipeline.") {
+		t.Errorf("report doesn't point at the pipeline:\n%s", rec.errs[0])
+	}
+}
+
+func TestIgnoreOptions(t *testing.T) {
+	rec := &recorder{}
+	check := leakcheck.Check(rec, leakcheck.Timeout(50*time.Millisecond),
+		leakcheck.Ignore(func(g leakcheck.Goroutine) bool { return strings.Contains(g.Stack, "pipeline.") }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	<-pipeline.From(ctx, 1, 2)
+	check()
+
+	if len(rec.errs) != 0 {
+		t.Errorf("ignored goroutine reported:\n%s", rec.errs[0])
+	}
+}
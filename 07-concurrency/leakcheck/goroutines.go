@@ -0,0 +1,112 @@
+// Package leakcheck finds goroutines that outlive the code that started
+// them.
+//
+// In tests, defer a check at the top of the test:
+//
+//	func TestWorker(t *testing.T) {
+//		defer leakcheck.Check(t)()
+//		...
+//	}
+//
+// In a running service, mount Handler on an admin port to see live
+// goroutines grouped by stack; a group whose count keeps growing is a leak.
+package leakcheck
+
+import (
+	"bytes"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Goroutine is one entry of a runtime.Stack(all) dump.
+type Goroutine struct {
+	ID int64
+	// State is what the scheduler reports, e.g. "chan receive" or
+	// "select, 2 minutes".
+	State string
+	// Top is the function the goroutine is currently in.
+	Top string
+	// CreatedBy is the function that started it, "" for main.
+	CreatedBy string
+	// Stack is the raw trace, without the "goroutine N [state]:" line.
+	Stack string
+}
+
+var header = regexp.MustCompile(`^goroutine (\d+) \[(.*)\]:$`)
+
+// All returns every live goroutine.
+func All() []Goroutine {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return parse(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+func parse(dump []byte) []Goroutine {
+	var out []Goroutine
+	for _, block := range bytes.Split(dump, []byte("\n\n")) {
+		lines := strings.Split(strings.TrimSpace(string(block)), "\n")
+		m := header.FindStringSubmatch(lines[0])
+		if m == nil {
+			continue
+		}
+		id, _ := strconv.ParseInt(m[1], 10, 64)
+		g := Goroutine{ID: id, State: m[2], Stack: strings.Join(lines[1:], "\n")}
+		if len(lines) > 1 {
+			g.Top = funcName(lines[1])
+		}
+		for _, l := range lines[1:] {
+			if fn, ok := strings.CutPrefix(l, "created by "); ok {
+				fn, _, _ = strings.Cut(fn, " in goroutine ")
+				g.CreatedBy = fn
+			}
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+// funcName strips the argument list from a stack frame line,
+// "main.worker(0x1, 0xc000010000)" -> "main.worker".
+func funcName(line string) string {
+	if i := strings.LastIndexByte(line, '('); i > 0 && strings.HasSuffix(line, ")") {
+		return line[:i]
+	}
+	return line
+}
+
+// frameOffsets and the creator's goroutine ID vary between otherwise
+// identical goroutines, as do call arguments.
+var (
+	frameOffsets = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+	inGoroutine  = regexp.MustCompile(` in goroutine \d+$`)
+)
+
+// signature is g's stack with everything that differs between goroutines
+// started from the same place removed, so they group together.
+func (g Goroutine) signature() string {
+	lines := strings.Split(g.Stack, "\n")
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "\t"):
+			lines[i] = frameOffsets.ReplaceAllString(l, "")
+		case strings.HasPrefix(l, "created by "):
+			lines[i] = inGoroutine.ReplaceAllString(l, "")
+		default:
+			lines[i] = funcName(l) + "(...)"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// baseState drops the wait duration, "chan receive, 5 minutes" -> "chan receive".
+func (g Goroutine) baseState() string {
+	s, _, _ := strings.Cut(g.State, ",")
+	return s
+}
@@ -0,0 +1,89 @@
+package leakcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Group is goroutines sharing a stack.
+type Group struct {
+	Count int `json:"count"`
+	// States counts members by scheduler state, without wait durations.
+	States map[string]int `json:"states"`
+	// Longest is the longest wait the runtime reported for a member, e.g.
+	// "12 minutes"; empty if none has been waiting for a minute yet.
+	Longest   string `json:"longest_wait,omitempty"`
+	Top       string `json:"top"`
+	CreatedBy string `json:"created_by,omitempty"`
+	Stack     string `json:"stack"`
+}
+
+// Groups buckets gs by stack, biggest group first.
+func Groups(gs []Goroutine) []Group {
+	byStack := map[string]*Group{}
+	longest := map[string]int{}
+	for _, g := range gs {
+		sig := g.signature()
+		grp, ok := byStack[sig]
+		if !ok {
+			grp = &Group{States: map[string]int{}, Top: g.Top, CreatedBy: g.CreatedBy, Stack: sig}
+			byStack[sig] = grp
+		}
+		grp.Count++
+		grp.States[g.baseState()]++
+		if mins := waitMinutes(g.State); mins > longest[sig] {
+			longest[sig] = mins
+			grp.Longest = fmt.Sprintf("%d minutes", mins)
+		}
+	}
+	out := make([]Group, 0, len(byStack))
+	for _, g := range byStack {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Top < out[j].Top
+	})
+	return out
+}
+
+// waitMinutes parses the ", N minutes" suffix the runtime adds to the state
+// of goroutines blocked for a minute or more.
+func waitMinutes(state string) int {
+	var n int
+	for i := 0; i < len(state); i++ {
+		if state[i] == ',' {
+			fmt.Sscanf(state[i+1:], " %d minutes", &n)
+			return n
+		}
+	}
+	return 0
+}
+
+// Handler serves live goroutines grouped by stack, as text or, with
+// ?format=json, as JSON. Stacks expose code paths, so mount it on an admin
+// listener only.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		all := All()
+		groups := Groups(all)
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"total": len(all), "groups": groups})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "%d goroutines in %d groups\n", len(all), len(groups))
+		for _, g := range groups {
+			fmt.Fprintf(w, "\n%d x %v", g.Count, g.States)
+			if g.Longest != "" {
+				fmt.Fprintf(w, " (longest wait %s)", g.Longest)
+			}
+			fmt.Fprintf(w, "\n%s\n", g.Stack)
+		}
+	})
+}
@@ -1,10 +1,27 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/actor"
+	"github.com/midsane/go-playground/07-concurrency/cache"
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/errgroup"
+	"github.com/midsane/go-playground/07-concurrency/leakcheck"
+	"github.com/midsane/go-playground/07-concurrency/pipeline"
+	"github.com/midsane/go-playground/07-concurrency/pool"
+	"github.com/midsane/go-playground/07-concurrency/pubsub"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+	"github.com/midsane/go-playground/07-concurrency/semaphore"
+	"github.com/midsane/go-playground/07-concurrency/singleflight"
 )
 
 /*
@@ -45,25 +62,71 @@ all write operations are blocked if there are current ongoing read operation, an
 write operation are blocked when a write is going on
 */
 type MidCache struct {
-	mu   sync.RWMutex
-	data map[int]int
+	mu    sync.RWMutex
+	data  map[int]int
+	loads singleflight.Group[int, int]
 }
 
 func (m *MidCache) Get(key int) (int, error) {
 	m.mu.RLock()
 	val, ok := m.data[key]
 	m.mu.RUnlock()
-	var err error
 	if !ok {
-		err = errors.New("key does not exist!")
+		// the same sentinel as cache.Cache, so errors.Is works against either
+		return 0, cache.ErrNotFound
 	}
-	return val, err
+	return val, nil
 }
 
+/*
+writes must take the write lock; RLock lets several writers into the map at
+once, which is a data race and can crash the runtime with "concurrent map writes".
+cache.Cache in ./cache is the generic version with TTLs, eviction and stats.
+*/
 func (m *MidCache) Set(key int, val int) {
-	m.mu.RLock()
+	m.mu.Lock()
 	m.data[key] = val
-	m.mu.RUnlock()
+	m.mu.Unlock()
+}
+
+/*
+on a miss every goroutine asking for the same hot key would go to the store at
+once (cache stampede). the singleflight group lets the first one load it and
+the rest wait for its result.
+*/
+func (m *MidCache) GetOrLoad(ctx context.Context, key int, load func(context.Context, int) (int, error)) (int, error) {
+	if val, err := m.Get(key); err == nil {
+		return val, nil
+	}
+	val, err, _ := m.loads.Do(ctx, key, func(ctx context.Context) (int, error) {
+		val, err := load(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		m.Set(key, val)
+		return val, nil
+	})
+	return val, err
+}
+
+func CacheStampede() {
+	mc := MidCache{data: make(map[int]int)}
+	var loads atomic.Int32
+	load := func(ctx context.Context, key int) (int, error) {
+		loads.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		return key * 10, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			val, err := mc.GetOrLoad(context.Background(), 7, load)
+			fmt.Println(val, err)
+		})
+	}
+	wg.Wait()
+	fmt.Println("store was hit", loads.Load(), "time(s) for 10 requests")
 }
 
 /*
@@ -148,13 +211,85 @@ func Semaphore() {
 	fmt.Println(counter)
 }
 
+/*
+the channel above is a semaphore with exactly one slot where every goroutine takes
+one unit. semaphore.Weighted has any number of units and callers take as many as
+they need, waiting their turn in order, giving up when their context ends.
+errgroup builds on it: run at most N at once, and the first failure cancels the rest.
+*/
+func WeightedSemaphore() {
+	ctx := context.Background()
+
+	// 4 units of DB capacity: a report query needs 3, a lookup needs 1
+	db := semaphore.NewWeighted(4)
+	var wg sync.WaitGroup
+	for i, cost := range []int64{1, 3, 1, 1, 3} {
+		wg.Go(func() {
+			if err := db.Acquire(ctx, cost); err != nil {
+				fmt.Println("query", i, err)
+				return
+			}
+			defer db.Release(cost)
+			fmt.Println("query", i, "running with weight", cost)
+			time.Sleep(200 * time.Millisecond)
+		})
+	}
+	wg.Wait()
+
+	g := errgroup.New(ctx)
+	g.SetLimit(2)
+	for i := range 6 {
+		g.Go(func(ctx context.Context) error {
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if i == 2 {
+				return fmt.Errorf("call %d: upstream returned 503", i)
+			}
+			fmt.Println("call", i, "ok")
+			return nil
+		})
+	}
+	fmt.Println("group:", g.Wait())
+}
+
+/*
+a panic inside a plain `go` statement kills the whole process, the caller's recover can't
+catch it since recover only works in the goroutine that panicked.
+goSafe starts f through safego, which recovers it and reports the panic + stack to the hook
+*/
+func goSafe(f func()) {
+	safego.Go(context.Background(), func(context.Context) error {
+		f()
+		return nil
+	})
+}
+
 func main() {
+	safego.SetHook(func(ctx context.Context, err error) {
+		var perr *safego.PanicError
+		if errors.As(err, &perr) {
+			log.Printf("goroutine panicked: %v\n%s", perr.Value, perr.Stack)
+			return
+		}
+		log.Println("goroutine failed:", err)
+	})
+
+	// WeightedSemaphore()
+	// PipelineSimulation()
+	// CacheStampede()
+	// FindLeaks()
 	// SendingWhileClosedLeadsToPanic()
 	// WaitOnTwoChannels()
 	// Timeout()
+	// FakeClock()
 	// NonBlockingChannel()
 	// SignalInit()
+	// Actors()
 	// BroadCast()
+	// PubSub()
 	FanInSimulation()
 }
 
@@ -273,6 +408,20 @@ func WaitOnTwoChannels() {
 
 }
 
+/*
+WaitOnTwoChannels and Timeout both leak -> the select returns, nobody ever receives
+from the other channel, so the sender goroutine stays blocked for the life of the process.
+leakcheck diffs the goroutines before and after and prints the ones still stuck
+*/
+func FindLeaks() {
+	before := leakcheck.Take()
+	WaitOnTwoChannels()
+	Timeout()
+	if leaked := leakcheck.Find(before, leakcheck.Timeout(3*time.Second)); len(leaked) > 0 {
+		fmt.Println(leakcheck.Report(leaked))
+	}
+}
+
 // how to implement timeout using select
 func Timeout() {
 	ch := make(chan string)
@@ -346,18 +495,124 @@ func Signalling(ch <-chan int, quit <-chan struct{}) {
 	}
 }
 
+/*
+Signalling and Worker are hand-rolled actors: one goroutine, a select over its
+channels, state nobody else touches. the actor package does that loop for us with
+a typed mailbox, request/reply, and a supervisor that restarts the goroutine with
+fresh state when it fails instead of leaving callers blocked forever.
+*/
+type sessionMsg interface{ isSessionMsg() }
+
+type addItem struct{ item string }
+type listItems struct{ reply chan<- []string }
+type corrupt struct{}
+
+func (addItem) isSessionMsg()   {}
+func (listItems) isSessionMsg() {}
+func (corrupt) isSessionMsg()   {}
+
+// cart is a per-user session; only its actor goroutine touches items
+type cart struct{ items []string }
+
+func (c *cart) Started(ctx context.Context) error {
+	fmt.Println("cart started")
+	return nil
+}
+
+func (c *cart) Receive(ctx context.Context, msg sessionMsg) error {
+	switch m := msg.(type) {
+	case addItem:
+		c.items = append(c.items, m.item)
+	case listItems:
+		m.reply <- slices.Clone(c.items)
+	case corrupt:
+		panic("cart state corrupted")
+	}
+	return nil
+}
+
+func Actors() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sup := actor.NewSupervisor(ctx, actor.SupervisorOptions{
+		Strategy: actor.OneForOne,
+		OnRestart: func(name string, err error) {
+			fmt.Println("restarting", name, "after:", err)
+		},
+	})
+	session := actor.Spawn(sup, "cart:42", func() actor.Behavior[sessionMsg] { return &cart{} })
+
+	list := func() {
+		items, err := actor.Ask(ctx, session, time.Second, func(r chan<- []string) sessionMsg { return listItems{r} })
+		fmt.Println("items:", items, err)
+	}
+
+	session.Send(ctx, addItem{"book"})
+	session.Send(ctx, addItem{"pen"})
+	list()
+
+	session.Send(ctx, corrupt{})
+	list() // the restarted cart starts empty
+
+	cancel()
+	<-sup.Done()
+	fmt.Println("send after stop:", session.Send(context.Background(), addItem{"lamp"}))
+}
+
 // select blocks until one case is ready
 // If multiple ready → random choice
 // default makes it non-blocking️
 // Closed channels are always ready to receive
 
+/*
+Timeout above really waits, so running it (or testing it) takes a second every time
+and the result depends on the scheduler. taking a clock.Clock instead of calling the
+time package directly lets the caller decide: clock.Real{} in production, a
+*clock.Fake that only moves on Advance in tests. FakeClock runs the same race and a
+cache expiry instantly and always with the same outcome.
+*/
+func TimeoutWithClock(clk clock.Clock, work time.Duration) string {
+	ch := make(chan string, 1)
+	go func() {
+		clk.Sleep(work)
+		ch <- "done"
+	}()
+
+	select {
+	case msg := <-ch:
+		return msg
+	case <-clk.After(time.Second):
+		return "Timeout"
+	}
+}
+
+func FakeClock() {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	res := make(chan string)
+	go func() { res <- TimeoutWithClock(fake, 3*time.Second) }()
+	fake.BlockUntil(2) // the worker's Sleep and the select's After
+	fake.Advance(3 * time.Second)
+	fmt.Println(<-res, "at", fake.Now().Format(time.TimeOnly))
+
+	c := cache.New(cache.Options[string, int]{TTL: time.Minute, Clock: fake})
+	c.Set("session", 42)
+	fake.Advance(59 * time.Second)
+	_, err := c.Get("session")
+	fmt.Println("after 59s:", err)
+	fake.Advance(time.Second)
+	_, err = c.Get("session")
+	fmt.Println("after 60s:", err)
+}
+
 // broadcast shutdown
 func BroadCast() {
 	quit := make(chan struct{})
 
-	go Worker(quit, "worker 1 have completed the task")
-	go Worker(quit, "worker 2 have completed the task")
-	go Worker(quit, "worker 3 have completed the task")
+	goSafe(func() { Worker(quit, "worker 1 have completed the task") })
+	goSafe(func() { Worker(quit, "worker 2 have completed the task") })
+	goSafe(func() { Worker(quit, "worker 3 have completed the task") })
 
 	time.Sleep(time.Second * 1)
 	close(quit)
@@ -367,6 +622,44 @@ func BroadCast() {
 	time.Sleep(time.Second * 3)
 }
 
+/*
+close(quit) can only say one thing, once, to everyone. pubsub.Broadcaster carries
+values, is reusable, and lets each subscriber pick topics ("cache.users.*") and
+what should happen when it falls behind. here two caches listen for invalidations;
+the slow one drops old messages instead of holding up the publisher.
+*/
+func PubSub() {
+	ctx := context.Background()
+	b := pubsub.New[string](pubsub.Buffer(4))
+
+	users, _ := b.Subscribe("cache.users.*")
+	all, _ := b.Subscribe("cache.>", pubsub.Buffer(2), pubsub.OnFull(pubsub.DropOldest))
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for msg := range users.C() {
+			fmt.Println("users cache: evict", msg.Value)
+		}
+		fmt.Println("users cache stopped:", users.Err())
+	})
+	wg.Go(func() {
+		for msg := range all.C() {
+			time.Sleep(100 * time.Millisecond)
+			fmt.Println("slow cache: evict", msg.Topic)
+		}
+		fmt.Println("slow cache stopped:", all.Err(), "dropped", all.Dropped())
+	})
+
+	for id := range 5 {
+		b.Publish(ctx, fmt.Sprintf("cache.users.%d", id), fmt.Sprint("user ", id))
+	}
+	b.Publish(ctx, "cache.orders.7", "order 7")
+
+	time.Sleep(500 * time.Millisecond)
+	b.Close()
+	wg.Wait()
+}
+
 func Worker(quit chan struct{}, str string) {
 	fmt.Println("working...")
 	msg := make(chan string)
@@ -386,27 +679,50 @@ func Worker(quit chan struct{}, str string) {
 	}
 }
 
-// basic load balancing, job spread out between workers
-func worker(id int, jobs <-chan int) {
-	for job := range jobs {
-		fmt.Println("worker", id, "processing", job)
-		time.Sleep(time.Second)
-	}
-}
-
+/*
+basic load balancing, job spread out between workers. a plain version of
+this guesses how long the jobs take with a sleep, and the workers can only
+print, the caller never sees a result or an error. pool keeps the same 3
+workers but hands back one result per job, so we wait for exactly as long
+as the work takes, and Shutdown drains whatever is still in flight.
+*/
 func LoadBalancer() {
-	jobs := make(chan int)
-
-	for i := 0; i < 3; i++ {
-		go worker(i, jobs)
+	ctx := context.Background()
+	p := pool.New(ctx, 3, func(ctx context.Context, job int) (string, error) {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if job == 4 {
+			return "", fmt.Errorf("job %d: bad input", job)
+		}
+		return fmt.Sprintf("job %d done", job), nil
+	}, pool.QueueSize(2))
+
+	var results []<-chan pool.Result[string]
+	for j := range 6 {
+		// blocks once 3 jobs are running and 2 are queued
+		res, err := p.Submit(ctx, j)
+		if err != nil {
+			fmt.Println("submit:", err)
+			continue
+		}
+		results = append(results, res)
 	}
-
-	for j := 0; j < 6; j++ {
-		jobs <- j
+	for _, res := range results {
+		r := <-res
+		fmt.Println(r.Value, r.Err)
 	}
 
-	close(jobs)
-	time.Sleep(5 * time.Second)
+	shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := p.Shutdown(shutdownCtx); err != nil {
+		fmt.Println("shutdown:", err)
+	}
+	s := p.Stats()
+	fmt.Printf("completed=%d failed=%d mean wait=%v mean run=%v\n",
+		s.Completed, s.Failed, s.MeanWait.Round(time.Millisecond), s.MeanRun.Round(time.Millisecond))
 }
 
 // Fan-in: merging multiple channels into one
@@ -414,7 +730,7 @@ func LoadBalancer() {
 // closing a
 func FanIn(ch1 <-chan int, ch2 <-chan int) <-chan int {
 	out := make(chan int)
-	go func() {
+	goSafe(func() {
 
 		defer func() {
 			close(out)
@@ -440,7 +756,7 @@ func FanIn(ch1 <-chan int, ch2 <-chan int) <-chan int {
 				out <- v
 			}
 		}
-	}()
+	})
 	return out
 }
 
@@ -470,3 +786,36 @@ func FanInSimulation() {
 	}
 
 }
+
+/*
+FanIn only handles exactly two channels of int. pipeline.Merge takes any number
+of channels of any type, and like every other stage in that package it stops
+and closes its output when the context is canceled, so nothing is left blocked.
+below: a small ETL job, extract -> transform in parallel (order kept) -> load
+in batches.
+*/
+func PipelineSimulation() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a := pipeline.From(ctx, "1", "2", "3")
+	b := pipeline.From(ctx, "4", "5")
+	c := pipeline.From(ctx, "6", "x", "8")
+	rows := pipeline.Merge(ctx, a, b, c)
+
+	parsed, errc := pipeline.Map(ctx, rows, 4, func(ctx context.Context, row string) (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		n, err := strconv.Atoi(row)
+		if err != nil {
+			return 0, fmt.Errorf("parse row %q: %w", row, err)
+		}
+		return n * n, nil
+	})
+
+	for batch := range pipeline.Batch(ctx, parsed, 3, 500*time.Millisecond) {
+		fmt.Println("load", batch)
+	}
+	if err := <-errc; err != nil {
+		fmt.Println("etl stopped:", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package pipeline has generic building blocks for channel pipelines.
+//
+// Every stage takes a context and follows the same rules: it owns and
+// closes the channels it returns, it closes them once its inputs are
+// exhausted or ctx is done, and it never leaves a goroutine blocked on a
+// send when ctx is canceled. So a consumer that stops early only has to
+// cancel ctx; it doesn't have to drain anything.
+//
+//	lines := pipeline.From(ctx, rows...)
+//	parsed, errc := pipeline.Map(ctx, lines, 8, parse)
+//	for batch := range pipeline.Batch(ctx, parsed, 500, time.Second) {
+//		insert(batch)
+//	}
+//	if err := <-errc; err != nil { ... }
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+)
+
+// send delivers v unless ctx ends first.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// From emits items in order.
+func From[T any](ctx context.Context, items ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range items {
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// OrDone forwards in until it's closed or ctx is done, so a range loop over
+// a channel you don't control can still be canceled.
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Merge fans any number of channels into one, closed when all of them are.
+// Order between inputs is not kept.
+func Merge[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Go(func() {
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FanOut spreads in over n channels. Each value goes to exactly one of
+// them, whichever consumer is ready first, so a slow consumer doesn't hold
+// the others up.
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, max(n, 1))
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+type result[T any] struct {
+	v   T
+	err error
+}
+
+// Map applies fn to every value with up to workers calls running at once,
+// and emits the results in input order. The first error stops the stage:
+// the output is closed and the error is sent on errc, which receives at
+// most one value and is closed after out. Canceling ctx before in is
+// exhausted counts as an error too: errc gets context.Cause(ctx), so a
+// consumer can tell a cut-short output from a complete one.
+func Map[In, Out any](ctx context.Context, in <-chan In, workers int, fn func(context.Context, In) (Out, error)) (<-chan Out, <-chan error) {
+	out := make(chan Out)
+	errc := make(chan error, 1)
+	ctx, cancel := context.WithCancel(ctx)
+
+	type task struct {
+		v    In
+		slot chan result[Out]
+	}
+	workers = max(workers, 1)
+	tasks := make(chan task)
+	// slots in input order; its buffer bounds how far workers run ahead of
+	// a slow item
+	order := make(chan chan result[Out], workers)
+
+	go func() {
+		defer close(tasks)
+		defer close(order)
+		for v := range OrDone(ctx, in) {
+			t := task{v, make(chan result[Out], 1)}
+			if !send(ctx, order, t.slot) || !send(ctx, tasks, t) {
+				return
+			}
+		}
+	}()
+
+	for range workers {
+		go func() {
+			for t := range tasks {
+				v, err := fn(ctx, t.v)
+				t.slot <- result[Out]{v, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+		defer cancel()
+		for slot := range order {
+			var r result[Out]
+			select {
+			case r = <-slot:
+			case <-ctx.Done():
+				errc <- context.Cause(ctx)
+				return
+			}
+			if r.err != nil {
+				errc <- r.err
+				return
+			}
+			if !send(ctx, out, r.v) {
+				errc <- context.Cause(ctx)
+				return
+			}
+		}
+		// order also ends early when the feeder sees ctx done
+		if err := context.Cause(ctx); err != nil {
+			errc <- err
+		}
+	}()
+	return out, errc
+}
+
+// Batch groups values into slices of up to size, emitting a short batch
+// when maxWait has passed since its first value. A zero maxWait only
+// flushes on size and at the end. The final partial batch is emitted when
+// in closes, but not when ctx is canceled.
+func Batch[T any](ctx context.Context, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	return BatchWithClock(ctx, clock.Real{}, in, size, maxWait)
+}
+
+// BatchWithClock is Batch with maxWait measured by clk, so a test can
+// drive the flush with a *clock.Fake.
+func BatchWithClock[T any](ctx context.Context, clk clock.Clock, in <-chan T, size int, maxWait time.Duration) <-chan []T {
+	out := make(chan []T)
+	size = max(size, 1)
+	go func() {
+		defer close(out)
+		var (
+			batch []T
+			timer clock.Timer
+			fire  <-chan time.Time
+		)
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				fire = nil
+			}
+			b := batch
+			batch = nil
+			return len(b) == 0 || send(ctx, out, b)
+		}
+		for {
+			select {
+			case v, ok := <-in:
+				if !ok {
+					flush()
+					return
+				}
+				batch = append(batch, v)
+				if len(batch) == 1 && maxWait > 0 {
+					if timer == nil {
+						timer = clk.NewTimer(maxWait)
+					} else {
+						timer.Reset(maxWait)
+					}
+					fire = timer.C()
+				}
+				if len(batch) >= size && !flush() {
+					return
+				}
+			case <-fire:
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Tee copies every value of in to both outputs. It moves at the pace of
+// the slower consumer: a value is delivered to both before the next one is
+// read.
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	out1, out2 := make(chan T), make(chan T)
+	go func() {
+		defer close(out1)
+		defer close(out2)
+		for v := range OrDone(ctx, in) {
+			// nil out whichever side got it so the other one gets it next
+			o1, o2 := out1, out2
+			for range 2 {
+				select {
+				case o1 <- v:
+					o1 = nil
+				case o2 <- v:
+					o2 = nil
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
+
+// Bridge flattens a channel of channels, reading each inner channel to the
+// end before moving to the next, so the order is kept.
+func Bridge[T any](ctx context.Context, chans <-chan <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for in := range OrDone(ctx, chans) {
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
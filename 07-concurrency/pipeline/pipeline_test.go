@@ -0,0 +1,173 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/pipeline"
+)
+
+func collect[T any](c <-chan T) []T {
+	var out []T
+	for v := range c {
+		out = append(out, v)
+	}
+	return out
+}
+
+func sorted(s []int) []int {
+	slices.Sort(s)
+	return s
+}
+
+func TestFromMergeFanOut(t *testing.T) {
+	ctx := context.Background()
+	if got := collect(pipeline.From(ctx, 1, 2, 3)); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("From = %v", got)
+	}
+	merged := collect(pipeline.Merge(ctx, pipeline.From(ctx, 1, 2), pipeline.From(ctx, 3), pipeline.From[int](ctx)))
+	if !slices.Equal(sorted(merged), []int{1, 2, 3}) {
+		t.Errorf("Merge = %v", merged)
+	}
+
+	outs := pipeline.FanOut(ctx, pipeline.From(ctx, 1, 2, 3, 4, 5, 6), 3)
+	fanned := collect(pipeline.Merge(ctx, outs...))
+	if !slices.Equal(sorted(fanned), []int{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("FanOut then Merge = %v", fanned)
+	}
+}
+
+func TestMapKeepsOrder(t *testing.T) {
+	ctx := context.Background()
+	out, errc := pipeline.Map(ctx, pipeline.From(ctx, 5, 4, 3, 2, 1), 4, func(_ context.Context, n int) (int, error) {
+		// the later items finish first
+		time.Sleep(time.Duration(n) * time.Millisecond)
+		return n * 10, nil
+	})
+	if got := collect(out); !slices.Equal(got, []int{50, 40, 30, 20, 10}) {
+		t.Errorf("Map = %v", got)
+	}
+	if err := <-errc; err != nil {
+		t.Errorf("errc = %v", err)
+	}
+}
+
+func TestMapStopsAtFirstError(t *testing.T) {
+	ctx := context.Background()
+	bad := errors.New("bad row")
+	out, errc := pipeline.Map(ctx, pipeline.From(ctx, 1, 2, 3, 4), 2, func(_ context.Context, n int) (int, error) {
+		if n == 3 {
+			return 0, bad
+		}
+		return n, nil
+	})
+	if got := collect(out); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("Map = %v, want what came before the error", got)
+	}
+	if err := <-errc; !errors.Is(err, bad) {
+		t.Errorf("errc = %v", err)
+	}
+	if _, open := <-errc; open {
+		t.Error("errc not closed")
+	}
+}
+
+func TestMapReportsCancellation(t *testing.T) {
+	stop := errors.New("operator stopped the job")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	in := make(chan int)
+	out, errc := pipeline.Map(ctx, in, 2, func(_ context.Context, n int) (int, error) { return n, nil })
+
+	in <- 1
+	if v := <-out; v != 1 {
+		t.Fatalf("got %d", v)
+	}
+	cancel(stop)
+	collect(out)
+	if err := <-errc; !errors.Is(err, stop) {
+		t.Errorf("errc = %v, want the cancel cause", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	got := collect(pipeline.Batch(ctx, pipeline.From(ctx, 1, 2, 3, 4, 5), 2, 0))
+	if len(got) != 3 || !slices.Equal(got[0], []int{1, 2}) || !slices.Equal(got[2], []int{5}) {
+		t.Errorf("Batch = %v", got)
+	}
+}
+
+func TestBatchFlushesAfterMaxWait(t *testing.T) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	in := make(chan int)
+	out := pipeline.BatchWithClock(context.Background(), f, in, 10, time.Second)
+
+	in <- 1
+	in <- 2
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	if b := <-out; !slices.Equal(b, []int{1, 2}) {
+		t.Errorf("first batch = %v", b)
+	}
+
+	// the wait starts again with the next batch's first value
+	in <- 3
+	f.BlockUntil(1)
+	f.Advance(time.Second - time.Nanosecond)
+	in <- 4
+	select {
+	case b := <-out:
+		t.Fatalf("flushed %v before maxWait", b)
+	default:
+	}
+	f.Advance(time.Nanosecond)
+	if b := <-out; !slices.Equal(b, []int{3, 4}) {
+		t.Errorf("second batch = %v", b)
+	}
+	close(in)
+	if b, ok := <-out; ok {
+		t.Errorf("empty batch emitted at the end: %v", b)
+	}
+}
+
+func TestTeeAndBridge(t *testing.T) {
+	ctx := context.Background()
+	a, b := pipeline.Tee(ctx, pipeline.From(ctx, 1, 2, 3))
+	var fromB []int
+	done := make(chan struct{})
+	go func() {
+		fromB = collect(b)
+		close(done)
+	}()
+	fromA := collect(a)
+	<-done
+	if !slices.Equal(fromA, []int{1, 2, 3}) || !slices.Equal(fromB, []int{1, 2, 3}) {
+		t.Errorf("Tee = %v, %v", fromA, fromB)
+	}
+
+	chans := make(chan (<-chan int), 2)
+	chans <- pipeline.From(ctx, 1, 2)
+	chans <- pipeline.From(ctx, 3)
+	close(chans)
+	if got := collect(pipeline.Bridge(ctx, chans)); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Bridge = %v", got)
+	}
+}
+
+func TestOrDoneStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := pipeline.OrDone(ctx, make(chan int)) // never sends or closes
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("value from a silent channel")
+		}
+	case <-time.After(time.Second):
+		t.Error("OrDone kept waiting after cancel")
+	}
+}
@@ -0,0 +1,332 @@
+// Package pool runs jobs on a fixed, resizable set of worker goroutines.
+//
+// Submitting blocks while the queue is full, which is the backpressure:
+// producers slow down to the rate the workers manage instead of piling up
+// goroutines or memory. Every job gets its own result, and Shutdown waits
+// for queued and running jobs to finish, up to a deadline.
+//
+//	p := pool.New(ctx, 4, resize)
+//	defer p.Shutdown(context.Background())
+//	img, err := p.Do(ctx, path)
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// ErrClosed is returned for jobs submitted after Shutdown started or the
+// pool's context ended.
+var ErrClosed = errors.New("pool: closed")
+
+// ErrQueueFull is returned by TrySubmit when the queue has no room.
+var ErrQueueFull = errors.New("pool: queue full")
+
+// Func processes one job. ctx is done when the job's own context is, or
+// when the pool is shut down without waiting.
+type Func[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Result is the outcome of one job. A panic in Func comes back as a
+// *safego.PanicError.
+type Result[Out any] struct {
+	Value Out
+	Err   error
+}
+
+// Option configures New.
+type Option func(*config)
+
+type config struct {
+	queue int
+	clock clock.Clock
+}
+
+// QueueSize sets how many jobs can wait for a worker. The default is the
+// number of workers.
+func QueueSize(n int) Option {
+	return func(c *config) { c.queue = n }
+}
+
+// Clock sets the clock used for the wait and run time metrics; the default
+// is the system clock.
+func Clock(c clock.Clock) Option {
+	return func(cfg *config) { cfg.clock = c }
+}
+
+// Stats is a snapshot of the pool's counters.
+type Stats struct {
+	Workers int
+	// Queued jobs are accepted but not started yet.
+	Queued  int
+	Running int
+	// Completed and Failed count jobs Func ran for; Failed is the subset
+	// that returned an error.
+	Completed uint64
+	Failed    uint64
+	// Aborted counts jobs that never reached Func: their context ended
+	// while queued, or the pool shut down under them.
+	Aborted uint64
+	// MeanWait is the average time a completed job spent queued, MeanRun
+	// the average time spent in Func.
+	MeanWait time.Duration
+	MeanRun  time.Duration
+}
+
+type job[In, Out any] struct {
+	ctx    context.Context
+	in     In
+	queued time.Time
+	res    chan Result[Out]
+}
+
+// Pool is safe for concurrent use.
+type Pool[In, Out any] struct {
+	fn     Func[In, Out]
+	clock  clock.Clock
+	jobs   chan job[In, Out]
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	size    int           // running workers
+	target  int           // workers wanted
+	wake    chan struct{} // closed to make idle workers re-check target
+	pending sync.WaitGroup
+	drained chan struct{} // closed once pending hits zero after close
+	workers sync.WaitGroup
+
+	running             atomic.Int64
+	completed, failed   atomic.Uint64
+	aborted             atomic.Uint64
+	waitTotal, runTotal atomic.Int64
+}
+
+// New starts n workers calling fn. When ctx ends the pool stops as if
+// Shutdown had timed out.
+func New[In, Out any](ctx context.Context, n int, fn Func[In, Out], opts ...Option) *Pool[In, Out] {
+	n = max(n, 1)
+	c := config{queue: n}
+	for _, o := range opts {
+		o(&c)
+	}
+	p := &Pool[In, Out]{
+		fn:    fn,
+		clock: clock.Or(c.clock),
+		jobs:  make(chan job[In, Out], max(c.queue, 0)),
+		wake:  make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	context.AfterFunc(p.ctx, p.abort)
+	p.Resize(n)
+	return p
+}
+
+// Submit queues in and returns a channel that receives its result. It
+// blocks while the queue is full, until ctx is done.
+func (p *Pool[In, Out]) Submit(ctx context.Context, in In) (<-chan Result[Out], error) {
+	j, err := p.accept(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case p.jobs <- j:
+		return j.res, nil
+	case <-ctx.Done():
+		p.pending.Done()
+		return nil, ctx.Err()
+	case <-p.ctx.Done():
+		p.pending.Done()
+		return nil, ErrClosed
+	}
+}
+
+// TrySubmit is Submit without waiting: it fails with ErrQueueFull instead.
+func (p *Pool[In, Out]) TrySubmit(ctx context.Context, in In) (<-chan Result[Out], error) {
+	j, err := p.accept(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case p.jobs <- j:
+		return j.res, nil
+	default:
+		p.pending.Done()
+		return nil, ErrQueueFull
+	}
+}
+
+// Do submits in and waits for its result.
+func (p *Pool[In, Out]) Do(ctx context.Context, in In) (Out, error) {
+	res, err := p.Submit(ctx, in)
+	if err != nil {
+		var zero Out
+		return zero, err
+	}
+	r := <-res
+	return r.Value, r.Err
+}
+
+func (p *Pool[In, Out]) accept(ctx context.Context, in In) (job[In, Out], error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return job[In, Out]{}, ErrClosed
+	}
+	// counted under the lock so close never races a new job in
+	p.pending.Add(1)
+	return job[In, Out]{ctx: ctx, in: in, queued: p.clock.Now(), res: make(chan Result[Out], 1)}, nil
+}
+
+// Resize sets the number of workers. Extra workers exit once they finish
+// their current job.
+func (p *Pool[In, Out]) Resize(n int) {
+	n = max(n, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.target = n
+	for p.size < p.target {
+		p.size++
+		p.workers.Add(1)
+		go p.work()
+	}
+	if p.size > p.target {
+		close(p.wake)
+		p.wake = make(chan struct{})
+	}
+}
+
+// Stats returns the pool's current counters.
+func (p *Pool[In, Out]) Stats() Stats {
+	p.mu.Lock()
+	workers := p.size
+	p.mu.Unlock()
+	s := Stats{
+		Workers:   workers,
+		Queued:    len(p.jobs),
+		Running:   int(p.running.Load()),
+		Completed: p.completed.Load(),
+		Failed:    p.failed.Load(),
+		Aborted:   p.aborted.Load(),
+	}
+	if s.Completed > 0 {
+		s.MeanWait = time.Duration(p.waitTotal.Load() / int64(s.Completed))
+		s.MeanRun = time.Duration(p.runTotal.Load() / int64(s.Completed))
+	}
+	return s
+}
+
+// Shutdown stops accepting jobs and waits for the queued and running ones
+// to finish. If ctx ends first, running jobs have their context canceled,
+// queued ones fail with ErrClosed, and Shutdown returns ctx's error without
+// waiting for Func calls that ignore cancellation.
+func (p *Pool[In, Out]) Shutdown(ctx context.Context) error {
+	drained := p.close()
+	select {
+	case <-drained:
+		p.cancel()
+		p.workers.Wait()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+// close marks the pool closed and returns a channel closed once every
+// accepted job has its result.
+func (p *Pool[In, Out]) close() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.drained = make(chan struct{})
+		go func() {
+			p.pending.Wait()
+			close(p.drained)
+		}()
+	}
+	return p.drained
+}
+
+// abort runs when the pool's context ends. Workers are gone or going, so
+// it fails whatever is still queued, including jobs that slip in from
+// Submit calls already past accept.
+func (p *Pool[In, Out]) abort() {
+	drained := p.close()
+	for {
+		select {
+		case j := <-p.jobs:
+			p.aborted.Add(1)
+			p.finish(j, Result[Out]{Err: ErrClosed})
+		case <-drained:
+			return
+		}
+	}
+}
+
+func (p *Pool[In, Out]) work() {
+	defer p.workers.Done()
+	for {
+		p.mu.Lock()
+		if p.size > p.target {
+			p.size--
+			p.mu.Unlock()
+			return
+		}
+		wake := p.wake
+		p.mu.Unlock()
+
+		select {
+		case j := <-p.jobs:
+			p.run(j)
+		case <-wake:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *Pool[In, Out]) run(j job[In, Out]) {
+	ctx, cancel := context.WithCancel(j.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+
+	wait := p.clock.Since(j.queued)
+	if err := ctx.Err(); err != nil {
+		p.aborted.Add(1)
+		p.finish(j, Result[Out]{Err: err})
+		return
+	}
+	p.running.Add(1)
+	start := p.clock.Now()
+	var out Out
+	err := safego.Run(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = p.fn(ctx, j.in)
+		return err
+	})
+	p.runTotal.Add(int64(p.clock.Since(start)))
+	p.waitTotal.Add(int64(wait))
+	p.completed.Add(1)
+	if err != nil {
+		p.failed.Add(1)
+	}
+	p.running.Add(-1)
+	p.finish(j, Result[Out]{Value: out, Err: err})
+}
+
+func (p *Pool[In, Out]) finish(j job[In, Out], r Result[Out]) {
+	j.res <- r
+	p.pending.Done()
+}
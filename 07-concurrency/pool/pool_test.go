@@ -0,0 +1,218 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/pool"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// gate is a Func that reports each start and then blocks until released
+// or its context ends.
+type gate struct {
+	started chan int
+	release chan struct{}
+}
+
+func newGate() *gate {
+	return &gate{started: make(chan int, 100), release: make(chan struct{})}
+}
+
+func (g *gate) fn(ctx context.Context, in int) (int, error) {
+	g.started <- in
+	select {
+	case <-g.release:
+		return in, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func submit(t *testing.T, p *pool.Pool[int, int], in int) <-chan pool.Result[int] {
+	t.Helper()
+	res, err := p.Submit(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestResize(t *testing.T) {
+	g := newGate()
+	p := pool.New(context.Background(), 1, g.fn, pool.QueueSize(10))
+	defer p.Shutdown(context.Background())
+
+	p.Resize(3)
+	var results []<-chan pool.Result[int]
+	for i := range 4 {
+		results = append(results, submit(t, p, i))
+	}
+	for range 3 {
+		<-g.started
+	}
+	if s := p.Stats(); s.Workers != 3 || s.Running != 3 || s.Queued != 1 {
+		t.Fatalf("after growing: %+v", s)
+	}
+
+	// shrinking doesn't interrupt running jobs; the extra workers leave
+	// as they finish
+	p.Resize(1)
+	close(g.release)
+	for _, res := range results {
+		if r := <-res; r.Err != nil {
+			t.Fatal(r.Err)
+		}
+	}
+	waitFor(t, "workers to exit", func() bool { return p.Stats().Workers == 1 })
+
+	g.release = make(chan struct{})
+	a, b := submit(t, p, 10), submit(t, p, 11)
+	<-g.started
+	time.Sleep(10 * time.Millisecond)
+	if s := p.Stats(); s.Running != 1 || s.Queued != 1 {
+		t.Errorf("after shrinking: %+v", s)
+	}
+	close(g.release)
+	<-a
+	<-b
+}
+
+func TestAbortDrainsQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := newGate()
+	p := pool.New(ctx, 1, g.fn, pool.QueueSize(3))
+	running := submit(t, p, 0)
+	<-g.started
+	var queued []<-chan pool.Result[int]
+	for i := range 3 {
+		queued = append(queued, submit(t, p, i+1))
+	}
+
+	cancel()
+	if r := <-running; !errors.Is(r.Err, context.Canceled) {
+		t.Errorf("running job: %v", r.Err)
+	}
+	for _, res := range queued {
+		// whichever of abort or a worker got to it first, it never ran
+		if r := <-res; !errors.Is(r.Err, pool.ErrClosed) && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("queued job: %v", r.Err)
+		}
+	}
+	if s := p.Stats(); s.Aborted != 3 || s.Completed != 1 {
+		t.Errorf("Stats = %+v", s)
+	}
+	if _, err := p.Submit(context.Background(), 9); !errors.Is(err, pool.ErrClosed) {
+		t.Errorf("Submit after abort = %v", err)
+	}
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown after abort = %v", err)
+	}
+}
+
+func TestShutdownWaitsForPendingJobs(t *testing.T) {
+	g := newGate()
+	p := pool.New(context.Background(), 1, g.fn, pool.QueueSize(1))
+	a, b := submit(t, p, 1), submit(t, p, 2)
+	<-g.started
+
+	// a Submit that gives up on the full queue must not stay pending, or
+	// Shutdown would wait for it forever
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Submit(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Submit on a full queue = %v", err)
+	}
+	if _, err := p.TrySubmit(context.Background(), 3); !errors.Is(err, pool.ErrQueueFull) {
+		t.Fatalf("TrySubmit on a full queue = %v", err)
+	}
+
+	done := make(chan error)
+	go func() { done <- p.Shutdown(context.Background()) }()
+	waitFor(t, "Shutdown to close the pool", func() bool {
+		_, err := p.TrySubmit(context.Background(), 4)
+		return errors.Is(err, pool.ErrClosed)
+	})
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned with jobs pending: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(g.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if ra, rb := <-a, <-b; ra.Err != nil || rb.Err != nil {
+		t.Errorf("results: %v, %v", ra.Err, rb.Err)
+	}
+}
+
+func TestShutdownDeadlineCancelsRunningJobs(t *testing.T) {
+	g := newGate()
+	p := pool.New(context.Background(), 1, g.fn)
+	res := submit(t, p, 1)
+	<-g.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v", err)
+	}
+	if r := <-res; !errors.Is(r.Err, context.Canceled) {
+		t.Errorf("running job: %v", r.Err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	first := make(chan struct{})
+	fn := func(ctx context.Context, in int) (int, error) {
+		switch in {
+		case 1:
+			<-first
+		case 3:
+			panic("boom")
+		case 4:
+			return 0, errors.New("failed")
+		}
+		f.Advance(2 * time.Second)
+		return in, nil
+	}
+	p := pool.New(context.Background(), 1, fn, pool.Clock(f))
+	defer p.Shutdown(context.Background())
+
+	// 1 starts at once and runs 2s; 2 is queued behind it for those 2s
+	a := submit(t, p, 1)
+	waitFor(t, "job 1 to start", func() bool { return p.Stats().Running == 1 })
+	b := submit(t, p, 2)
+	close(first)
+	<-a
+	<-b
+	if s := p.Stats(); s.Completed != 2 || s.MeanWait != time.Second || s.MeanRun != 2*time.Second {
+		t.Errorf("Stats = %+v, want MeanWait 1s and MeanRun 2s", s)
+	}
+
+	var perr *safego.PanicError
+	if r := <-submit(t, p, 3); !errors.As(r.Err, &perr) {
+		t.Errorf("panicking job: %v", r.Err)
+	}
+	<-submit(t, p, 4)
+	if s := p.Stats(); s.Completed != 4 || s.Failed != 2 {
+		t.Errorf("Stats = %+v, want 4 completed, 2 failed", s)
+	}
+}
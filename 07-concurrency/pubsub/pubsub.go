@@ -0,0 +1,293 @@
+// Package pubsub is an in-process publish/subscribe broadcaster.
+//
+// Topics are dot-separated, like "cache.users.42". Subscription patterns
+// may use "*" for exactly one segment ("cache.*.42") and a trailing ">" for
+// one or more ("cache.>").
+//
+// Every subscriber has its own buffered channel, so one slow consumer
+// doesn't have to hold up the others; what happens when its buffer is full
+// is its Policy.
+//
+//	b := pubsub.New[Event]()
+//	sub, _ := b.Subscribe("orders.>", pubsub.Buffer(64), pubsub.OnFull(pubsub.DropOldest))
+//	defer sub.Unsubscribe()
+//	for msg := range sub.C() { ... }
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrClosed is returned after Close, and is the Err of subscriptions
+	// that Close ended.
+	ErrClosed = errors.New("pubsub: broadcaster closed")
+	// ErrSlowConsumer is the Err of subscriptions dropped by Disconnect.
+	ErrSlowConsumer = errors.New("pubsub: subscriber too slow")
+	// ErrUnsubscribed is the Err of subscriptions ended by Unsubscribe.
+	ErrUnsubscribed = errors.New("pubsub: unsubscribed")
+)
+
+// Policy is what Publish does when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Block waits for room, bounded by Publish's context. Everyone gets
+	// everything, at the pace of the slowest subscriber.
+	Block Policy = iota
+	// DropOldest discards the oldest buffered message to make room; good
+	// for state updates where only the latest matters.
+	DropOldest
+	// DropNewest discards the message being published.
+	DropNewest
+	// Disconnect ends the subscription with ErrSlowConsumer, e.g. to make
+	// a websocket client reconnect and resync.
+	Disconnect
+)
+
+// Message is what subscribers receive.
+type Message[T any] struct {
+	Topic string
+	Value T
+}
+
+// Option configures a subscription, or the defaults when passed to New.
+type Option func(*config)
+
+type config struct {
+	buffer int
+	policy Policy
+}
+
+// Buffer sets the subscriber's channel capacity. The default is 16. The
+// drop policies need somewhere to drop from, so with those it is at least 1.
+func Buffer(n int) Option {
+	return func(c *config) { c.buffer = max(n, 0) }
+}
+
+// OnFull sets the slow-consumer policy. The default is Block.
+func OnFull(p Policy) Option {
+	return func(c *config) { c.policy = p }
+}
+
+// Broadcaster is safe for concurrent use. Use New.
+type Broadcaster[T any] struct {
+	defaults []Option
+
+	mu     sync.RWMutex
+	subs   map[*Subscription[T]]struct{}
+	closed bool
+}
+
+// New returns a broadcaster; opts are defaults for every Subscribe.
+func New[T any](opts ...Option) *Broadcaster[T] {
+	return &Broadcaster[T]{defaults: opts, subs: make(map[*Subscription[T]]struct{})}
+}
+
+// Subscribe starts receiving messages whose topic matches pattern.
+func (b *Broadcaster[T]) Subscribe(pattern string, opts ...Option) (*Subscription[T], error) {
+	p, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c := config{buffer: 16, policy: Block}
+	for _, o := range b.defaults {
+		o(&c)
+	}
+	for _, o := range opts {
+		o(&c)
+	}
+	if c.policy == DropOldest || c.policy == DropNewest {
+		c.buffer = max(c.buffer, 1)
+	}
+	s := &Subscription[T]{
+		b:       b,
+		pattern: p,
+		policy:  c.policy,
+		ch:      make(chan Message[T], c.buffer),
+		done:    make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Publish delivers value to every matching subscriber, applying each one's
+// policy. It returns ctx's error if a Block subscriber was still full when
+// ctx ended; the subscribers after it are still tried without waiting.
+func (b *Broadcaster[T]) Publish(ctx context.Context, topic string, value T) error {
+	if err := checkTopic(topic); err != nil {
+		return err
+	}
+	segs := strings.Split(topic, ".")
+
+	// deliver outside the broadcaster lock, so a blocked send can't hold up
+	// Subscribe, Unsubscribe or other topics
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrClosed
+	}
+	var targets []*Subscription[T]
+	for s := range b.subs {
+		if s.pattern.match(segs) {
+			targets = append(targets, s)
+		}
+	}
+	b.mu.RUnlock()
+
+	msg := Message[T]{Topic: topic, Value: value}
+	var err error
+	for _, s := range targets {
+		if !s.deliver(ctx, msg) && err == nil {
+			err = ctx.Err()
+		}
+	}
+	return err
+}
+
+// Close ends every subscription with ErrClosed. Later Publish and
+// Subscribe calls fail with ErrClosed.
+func (b *Broadcaster[T]) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.closed = true
+	b.mu.Unlock()
+	for s := range subs {
+		s.end(ErrClosed)
+	}
+}
+
+// Len is the number of live subscriptions.
+func (b *Broadcaster[T]) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Subscription is one subscriber's end.
+type Subscription[T any] struct {
+	b       *Broadcaster[T]
+	pattern pattern
+	policy  Policy
+	ch      chan Message[T]
+	done    chan struct{} // closed first on end, to release blocked senders
+	dropped atomic.Uint64
+
+	mu     sync.Mutex // serializes senders and guards ch's close
+	err    error      // set before done is closed
+	ending sync.Once
+}
+
+// C receives the messages. It's closed when the subscription ends; Err
+// then says why.
+func (s *Subscription[T]) C() <-chan Message[T] { return s.ch }
+
+// Done is closed when the subscription ends, before C has been drained.
+func (s *Subscription[T]) Done() <-chan struct{} { return s.done }
+
+// Err is nil while the subscription is live.
+func (s *Subscription[T]) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Dropped counts messages lost to DropOldest or DropNewest.
+func (s *Subscription[T]) Dropped() uint64 { return s.dropped.Load() }
+
+// Unsubscribe ends the subscription. It's safe to call more than once and
+// from the goroutine reading C.
+func (s *Subscription[T]) Unsubscribe() {
+	s.end(ErrUnsubscribed)
+}
+
+func (s *Subscription[T]) end(err error) {
+	s.ending.Do(func() {
+		s.err = err
+		close(s.done)
+		s.b.mu.Lock()
+		delete(s.b.subs, s)
+		s.b.mu.Unlock()
+
+		s.mu.Lock()
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// deliver reports false only when a Block send gave up because ctx ended.
+func (s *Subscription[T]) deliver(ctx context.Context, msg Message[T]) bool {
+	s.mu.Lock()
+	select {
+	case <-s.done:
+		s.mu.Unlock()
+		return true
+	default:
+	}
+	switch s.policy {
+	case DropNewest:
+		select {
+		case s.ch <- msg:
+		default:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		// the publisher is the only sender and holds mu, so after one
+		// eviction there is room; the second try is just a bound
+		sent := false
+		for try := 0; try < 2 && !sent; try++ {
+			select {
+			case s.ch <- msg:
+				sent = true
+			default:
+				select {
+				case <-s.ch:
+					s.dropped.Add(1)
+				default:
+				}
+			}
+		}
+		if !sent {
+			s.dropped.Add(1)
+		}
+	case Disconnect:
+		select {
+		case s.ch <- msg:
+		default:
+			s.mu.Unlock()
+			s.end(ErrSlowConsumer)
+			return true
+		}
+	default:
+		// try without waiting first: once ctx is done a select with both
+		// cases ready picks at random, and a subscriber with room must not
+		// lose the message to that
+		select {
+		case s.ch <- msg:
+		default:
+			select {
+			case s.ch <- msg:
+			case <-s.done:
+			case <-ctx.Done():
+				s.mu.Unlock()
+				return false
+			}
+		}
+	}
+	s.mu.Unlock()
+	return true
+}
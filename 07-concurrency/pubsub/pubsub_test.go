@@ -0,0 +1,159 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"cache.users.42", "cache.users.42", true},
+		{"cache.*.42", "cache.users.42", true},
+		{"cache.*.42", "cache.users.43", false},
+		{"cache.*", "cache.users.42", false},
+		{"cache.>", "cache.users.42", true},
+		{"cache.>", "cache", false},
+		{">", "anything.at.all", true},
+	} {
+		p, err := parsePattern(tc.pattern)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.pattern, err)
+		}
+		if got := p.match(strings.Split(tc.topic, ".")); got != tc.want {
+			t.Errorf("%q matching %q = %v", tc.pattern, tc.topic, got)
+		}
+	}
+	for _, bad := range []string{"", "a..b", "a.>.b", "."} {
+		if _, err := parsePattern(bad); err == nil {
+			t.Errorf("pattern %q accepted", bad)
+		}
+	}
+}
+
+func TestBlockSkipsFullSubscriberOnlyOnceCtxIsDone(t *testing.T) {
+	// the old code raced a ready send against a done ctx; enough rounds
+	// made it lose the message for the subscriber with room
+	for range 200 {
+		b := New[int](OnFull(Block), Buffer(1))
+		full, _ := b.Subscribe("t")
+		roomy, _ := b.Subscribe("t")
+		if err := b.Publish(context.Background(), "t", 1); err != nil {
+			t.Fatal(err)
+		}
+		<-roomy.C()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := b.Publish(ctx, "t", 2); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Publish = %v, want context.Canceled for the full subscriber", err)
+		}
+		select {
+		case m := <-roomy.C():
+			if m.Value != 2 {
+				t.Fatalf("got %d", m.Value)
+			}
+		default:
+			t.Fatal("subscriber with room lost the message")
+		}
+		if m := <-full.C(); m.Value != 1 {
+			t.Fatalf("full subscriber got %d", m.Value)
+		}
+		b.Close()
+	}
+}
+
+func TestBlockWaitsForRoom(t *testing.T) {
+	b := New[int]()
+	sub, _ := b.Subscribe("t", Buffer(0))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-sub.C()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Publish(ctx, "t", 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDropPolicies(t *testing.T) {
+	b := New[int]()
+	oldest, _ := b.Subscribe("t", Buffer(2), OnFull(DropOldest))
+	newest, _ := b.Subscribe("t", Buffer(2), OnFull(DropNewest))
+	unbuffered, _ := b.Subscribe("t", Buffer(0), OnFull(DropOldest))
+	for v := range 4 {
+		if err := b.Publish(context.Background(), "t", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Close()
+
+	for _, tc := range []struct {
+		name    string
+		sub     *Subscription[int]
+		want    []int
+		dropped uint64
+	}{
+		{"DropOldest", oldest, []int{2, 3}, 2},
+		{"DropNewest", newest, []int{0, 1}, 2},
+		{"DropOldest with Buffer(0)", unbuffered, []int{3}, 3},
+	} {
+		var got []int
+		for m := range tc.sub.C() {
+			got = append(got, m.Value)
+		}
+		if len(got) != len(tc.want) || got[0] != tc.want[0] || got[len(got)-1] != tc.want[len(tc.want)-1] {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if d := tc.sub.Dropped(); d != tc.dropped {
+			t.Errorf("%s: dropped %d, want %d", tc.name, d, tc.dropped)
+		}
+		if !errors.Is(tc.sub.Err(), ErrClosed) {
+			t.Errorf("%s: Err = %v", tc.name, tc.sub.Err())
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	b := New[int]()
+	sub, _ := b.Subscribe("t", Buffer(1), OnFull(Disconnect))
+	b.Publish(context.Background(), "t", 1)
+	b.Publish(context.Background(), "t", 2)
+	<-sub.Done()
+	if !errors.Is(sub.Err(), ErrSlowConsumer) {
+		t.Errorf("Err = %v", sub.Err())
+	}
+	if b.Len() != 0 {
+		t.Errorf("%d subscriptions left", b.Len())
+	}
+}
+
+func TestUnsubscribeReleasesBlockedPublish(t *testing.T) {
+	b := New[int]()
+	sub, _ := b.Subscribe("t", Buffer(0))
+	done := make(chan error)
+	go func() { done <- b.Publish(context.Background(), "t", 1) }()
+	time.Sleep(10 * time.Millisecond)
+	sub.Unsubscribe()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Publish = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish stayed blocked on an ended subscription")
+	}
+	if _, err := b.Subscribe("t"); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+	if err := b.Publish(context.Background(), "t", 1); !errors.Is(err, ErrClosed) {
+		t.Errorf("Publish after Close = %v", err)
+	}
+}
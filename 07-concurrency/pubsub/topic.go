@@ -0,0 +1,46 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrBadTopic is returned for empty topics, empty segments, and wildcards
+// where they aren't allowed.
+var ErrBadTopic = errors.New("pubsub: bad topic")
+
+// pattern is a parsed subscription pattern.
+type pattern []string
+
+func parsePattern(s string) (pattern, error) {
+	segs := strings.Split(s, ".")
+	for i, seg := range segs {
+		if seg == "" || (seg == ">" && i != len(segs)-1) {
+			return nil, ErrBadTopic
+		}
+	}
+	return segs, nil
+}
+
+func checkTopic(s string) error {
+	for _, seg := range strings.Split(s, ".") {
+		if seg == "" || seg == "*" || seg == ">" {
+			return ErrBadTopic
+		}
+	}
+	return nil
+}
+
+// match reports whether topic, already split, fits p: "*" matches exactly
+// one segment, a trailing ">" one or more.
+func (p pattern) match(topic []string) bool {
+	for i, seg := range p {
+		if seg == ">" {
+			return len(topic) > i
+		}
+		if i >= len(topic) || (seg != "*" && seg != topic[i]) {
+			return false
+		}
+	}
+	return len(p) == len(topic)
+}
@@ -0,0 +1,192 @@
+// Package safego starts goroutines that can't take the process down.
+//
+// A panic in a goroutine started with plain `go` kills the whole program,
+// no matter what the caller had deferred. Everything in here recovers it
+// instead, turns it into a *PanicError carrying the stack, and hands it to
+// the hook set with SetHook, the same way SafeParsing and Yep in
+// 00-go-basics do for a single call.
+package safego
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+)
+
+// PanicError is a recovered panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it was an error, so errors.Is works on
+// panic(err).
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Hook receives errors from goroutines started by this package.
+type Hook func(ctx context.Context, err error)
+
+var hook atomic.Pointer[Hook]
+
+// SetHook installs h as the process-wide error hook. nil removes it.
+func SetHook(h Hook) {
+	if h == nil {
+		hook.Store(nil)
+		return
+	}
+	hook.Store(&h)
+}
+
+func report(ctx context.Context, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	if h := hook.Load(); h != nil {
+		(*h)(ctx, err)
+	}
+}
+
+// Run calls fn and returns its error, or a *PanicError if it panicked.
+// It doesn't report; that's up to the caller.
+func Run(ctx context.Context, fn func(context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx)
+}
+
+// Go runs fn in a new goroutine. A non-nil result, panics included, goes to
+// the hook and is also sent on the returned channel, which gets exactly one
+// value and can be ignored.
+func Go(ctx context.Context, fn func(context.Context) error) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		err := Run(ctx, fn)
+		report(ctx, err)
+		done <- err
+	}()
+	return done
+}
+
+// Group runs a set of goroutines and collects every error they return or
+// panic with.
+type Group struct {
+	ctx  context.Context
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	errs []error
+}
+
+// NewGroup returns a Group whose goroutines receive ctx.
+func NewGroup(ctx context.Context) *Group {
+	return &Group{ctx: ctx}
+}
+
+// Go starts fn in the group.
+func (g *Group) Go(fn func(context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := Run(g.ctx, fn); err != nil {
+			report(g.ctx, err)
+			g.mu.Lock()
+			g.errs = append(g.errs, err)
+			g.mu.Unlock()
+		}
+	}()
+}
+
+// Wait blocks until every goroutine has returned and joins their errors.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return errors.Join(g.errs...)
+}
+
+// Backoff is the restart policy for Loop.
+type Backoff struct {
+	// Initial is the first delay, 100ms if zero.
+	Initial time.Duration
+	// Max caps the delay, 30s if zero.
+	Max time.Duration
+	// Factor multiplies the delay after each failure, 2 if zero.
+	Factor float64
+	// Reset puts the delay back to Initial after a run that lasted this
+	// long, 1m if zero, so a loop that was healthy for a while doesn't come
+	// back slowly.
+	Reset time.Duration
+	// Clock times the runs and delays; nil means the system clock.
+	Clock clock.Clock
+}
+
+func (b Backoff) withDefaults() Backoff {
+	if b.Initial <= 0 {
+		b.Initial = 100 * time.Millisecond
+	}
+	if b.Max <= 0 {
+		b.Max = 30 * time.Second
+	}
+	if b.Factor < 1 {
+		b.Factor = 2
+	}
+	if b.Reset <= 0 {
+		b.Reset = time.Minute
+	}
+	b.Clock = clock.Or(b.Clock)
+	return b
+}
+
+// Loop runs a long-lived fn in a new goroutine and restarts it with backoff
+// every time it fails or panics. It stops when fn returns nil or ctx is
+// done; the returned channel is closed then, after receiving ctx.Err() if
+// that was the reason.
+func Loop(ctx context.Context, b Backoff, fn func(context.Context) error) <-chan error {
+	b = b.withDefaults()
+	done := make(chan error, 1)
+	go func() {
+		defer close(done)
+		delay := b.Initial
+		for {
+			start := b.Clock.Now()
+			err := Run(ctx, fn)
+			if err == nil {
+				return
+			}
+			if ctx.Err() != nil {
+				done <- ctx.Err()
+				return
+			}
+			report(ctx, err)
+
+			if b.Clock.Since(start) >= b.Reset {
+				delay = b.Initial
+			}
+			t := b.Clock.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				done <- ctx.Err()
+				return
+			case <-t.C():
+			}
+			delay = min(time.Duration(float64(delay)*b.Factor), b.Max)
+		}
+	}()
+	return done
+}
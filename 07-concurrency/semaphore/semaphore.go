@@ -0,0 +1,117 @@
+// Package semaphore is a weighted semaphore: a pool of n units that callers
+// take and give back in any amount, e.g. one unit per DB connection or a
+// request's size in bytes.
+//
+// Waiters are served strictly in arrival order. A large Acquire at the
+// head of the queue holds back smaller ones behind it even if they would
+// fit, so big requests can't be starved by a stream of small ones.
+package semaphore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Weighted is safe for concurrent use. Use NewWeighted.
+type Weighted struct {
+	size    int64
+	mu      sync.Mutex
+	cur     int64
+	waiters list.List // of waiter
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{} // closed when the units are granted
+}
+
+// NewWeighted returns a semaphore with n units.
+func NewWeighted(n int64) *Weighted {
+	return &Weighted{size: n}
+}
+
+// Acquire takes n units, waiting until they are free or ctx is done. On
+// failure it takes nothing and returns ctx's error. Asking for more than
+// the semaphore's size waits until ctx is done.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	w := waiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		select {
+		case <-w.ready:
+			// granted just before ctx ended; the grant wins
+			return nil
+		default:
+		}
+		isFront := s.waiters.Front() == elem
+		s.waiters.Remove(elem)
+		// the head leaving may let the ones behind it through
+		if isFront && s.size > s.cur {
+			s.notifyWaiters()
+		}
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes n units if they are free right now and nobody is
+// queued ahead, and reports whether it did.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release returns n units. Releasing more than is held panics, as it
+// always means a bug in the caller.
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+func (s *Weighted) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(waiter)
+		if s.size-s.cur < w.n {
+			// FIFO: don't let smaller waiters jump the queue
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
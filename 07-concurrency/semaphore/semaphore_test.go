@@ -0,0 +1,165 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// acquireAsync starts Acquire(ctx, n), waits until it is queued, and
+// returns a channel that gets its result.
+func acquireAsync(t *testing.T, s *Weighted, ctx context.Context, n int64) <-chan error {
+	t.Helper()
+	s.mu.Lock()
+	queued := s.waiters.Len()
+	s.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Acquire(ctx, n) }()
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		l := s.waiters.Len()
+		s.mu.Unlock()
+		if l > queued {
+			return done
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Acquire(%d) never queued", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func pending(c <-chan error) bool {
+	select {
+	case <-c:
+		return false
+	default:
+		return true
+	}
+}
+
+func TestTryAcquireAndRelease(t *testing.T) {
+	s := NewWeighted(3)
+	if !s.TryAcquire(2) || s.TryAcquire(2) || !s.TryAcquire(1) {
+		t.Fatal("TryAcquire ignored the free units")
+	}
+	s.Release(3)
+	if !s.TryAcquire(3) {
+		t.Fatal("released units weren't free again")
+	}
+	s.Release(3)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("over-release didn't panic")
+		}
+	}()
+	s.Release(1)
+}
+
+func TestFIFOWeighted(t *testing.T) {
+	s := NewWeighted(10)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	big := acquireAsync(t, s, ctx, 5)
+	small := acquireAsync(t, s, ctx, 1)
+
+	// one unit free: enough for small, but big is ahead of it
+	s.Release(1)
+	time.Sleep(10 * time.Millisecond)
+	if !pending(big) || !pending(small) {
+		t.Fatal("a waiter got through with only one unit free")
+	}
+	if s.TryAcquire(1) {
+		t.Fatal("TryAcquire jumped the queue")
+	}
+
+	s.Release(4)
+	if err := <-big; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if !pending(small) {
+		t.Fatal("small got a unit that wasn't free")
+	}
+	s.Release(1)
+	if err := <-small; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCanceledHeadLetsOthersThrough(t *testing.T) {
+	s := NewWeighted(2)
+	bg := context.Background()
+	s.Acquire(bg, 1)
+	ctx, cancel := context.WithCancel(bg)
+	head := acquireAsync(t, s, ctx, 2)
+	behind := acquireAsync(t, s, bg, 1)
+
+	cancel()
+	if err := <-head; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled Acquire = %v", err)
+	}
+	select {
+	case err := <-behind:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiter behind a canceled head stayed queued")
+	}
+	if s.TryAcquire(1) {
+		t.Error("the canceled Acquire left its units taken or released too many")
+	}
+}
+
+func TestAcquireFailsWithoutTakingUnits(t *testing.T) {
+	s := NewWeighted(2)
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Acquire(done, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire on a done ctx = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire above the size = %v", err)
+	}
+	if !s.TryAcquire(2) {
+		t.Error("failed Acquires took units")
+	}
+}
+
+func TestNeverOverCommits(t *testing.T) {
+	const size = 5
+	s := NewWeighted(size)
+	var inUse, peak atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 50 {
+		n := int64(i%size + 1)
+		wg.Go(func() {
+			if err := s.Acquire(context.Background(), n); err != nil {
+				t.Error(err)
+				return
+			}
+			cur := inUse.Add(n)
+			for p := peak.Load(); cur > p && !peak.CompareAndSwap(p, cur); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			inUse.Add(-n)
+			s.Release(n)
+		})
+	}
+	wg.Wait()
+	if p := peak.Load(); p > size {
+		t.Errorf("%d units in use at once, size %d", p, size)
+	}
+}
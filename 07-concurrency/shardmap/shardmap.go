@@ -0,0 +1,221 @@
+// Package shardmap is a concurrent map split into independently locked
+// shards, for workloads where one sync.RWMutex around a map (MidCache in
+// 07-concurrency, userStore in 08-http-server) is the bottleneck.
+//
+// Each key lives in exactly one shard, picked by its hash, so operations on
+// different shards never wait for each other. Operations on a single key
+// are atomic; nothing spans shards, so Len and All are only consistent
+// within each shard.
+package shardmap
+
+import (
+	"hash/maphash"
+	"iter"
+	"runtime"
+	"sync"
+)
+
+// Hasher maps a key to a shard. It must be deterministic for the life of
+// the map and should spread keys evenly.
+type Hasher[K comparable] func(K) uint64
+
+// Options configures a Map. The zero value is fine.
+type Options[K comparable] struct {
+	// Shards is rounded up to a power of two. It defaults to 4×GOMAXPROCS,
+	// enough that two busy goroutines rarely share one.
+	Shards int
+	// Hash defaults to hash/maphash with a random seed.
+	Hash Hasher[K]
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+	// keeps neighbouring shards' locks off the same cache line
+	_ [64]byte
+}
+
+// Map is safe for concurrent use. Use New; the zero Map is not usable.
+type Map[K comparable, V any] struct {
+	shards []shard[K, V]
+	mask   uint64
+	hash   Hasher[K]
+}
+
+// New returns an empty map.
+func New[K comparable, V any](opts Options[K]) *Map[K, V] {
+	n := opts.Shards
+	if n <= 0 {
+		n = 4 * runtime.GOMAXPROCS(0)
+	}
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	hash := opts.Hash
+	if hash == nil {
+		seed := maphash.MakeSeed()
+		hash = func(k K) uint64 { return maphash.Comparable(seed, k) }
+	}
+	m := &Map[K, V]{
+		shards: make([]shard[K, V], size),
+		mask:   uint64(size - 1),
+		hash:   hash,
+	}
+	for i := range m.shards {
+		m.shards[i].m = make(map[K]V)
+	}
+	return m
+}
+
+func (m *Map[K, V]) shard(key K) *shard[K, V] {
+	return &m.shards[m.hash(key)&m.mask]
+}
+
+// Shards is the number of shards actually in use.
+func (m *Map[K, V]) Shards() int { return len(m.shards) }
+
+// Get returns the value for key and whether it was present.
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	v, ok := s.m[key]
+	s.mu.RUnlock()
+	return v, ok
+}
+
+// Set stores value for key.
+func (m *Map[K, V]) Set(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	s.m[key] = value
+	s.mu.Unlock()
+}
+
+// Delete removes key and reports whether it was present.
+func (m *Map[K, V]) Delete(key K) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	_, ok := s.m[key]
+	delete(s.m, key)
+	s.mu.Unlock()
+	return ok
+}
+
+// GetOrSet returns the existing value for key if there is one. Otherwise
+// it stores value and returns it. loaded reports which happened.
+func (m *Map[K, V]) GetOrSet(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	// most calls on a warm map are hits, which only need the read lock
+	s.mu.RLock()
+	v, ok := s.m[key]
+	s.mu.RUnlock()
+	if ok {
+		return v, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.m[key]; ok {
+		return v, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+// Compute replaces key's value with fn's result, atomically. fn gets the
+// current value and whether it exists; if it returns keep == false the key
+// is deleted instead. Compute returns what is stored afterwards.
+//
+// fn runs with the shard locked, so it must be quick and must not use the
+// map.
+func (m *Map[K, V]) Compute(key K, fn func(old V, exists bool) (value V, keep bool)) (V, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.m[key]
+	v, keep := fn(old, ok)
+	if !keep {
+		delete(s.m, key)
+		var zero V
+		return zero, false
+	}
+	s.m[key] = v
+	return v, true
+}
+
+// CompareAndSwap stores new for key if its current value equals old. Like
+// sync.Map's, it panics if V isn't comparable at run time.
+func (m *Map[K, V]) CompareAndSwap(key K, old, new V) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[key]
+	if !ok || any(cur) != any(old) {
+		return false
+	}
+	s.m[key] = new
+	return true
+}
+
+// CompareAndDelete deletes key if its current value equals old.
+func (m *Map[K, V]) CompareAndDelete(key K, old V) bool {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.m[key]
+	if !ok || any(cur) != any(old) {
+		return false
+	}
+	delete(s.m, key)
+	return true
+}
+
+// Len sums the shard sizes. Under concurrent writes it's approximate.
+func (m *Map[K, V]) Len() int {
+	n := 0
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Clear empties every shard.
+func (m *Map[K, V]) Clear() {
+	for i := range m.shards {
+		s := &m.shards[i]
+		s.mu.Lock()
+		clear(s.m)
+		s.mu.Unlock()
+	}
+}
+
+// All iterates over the map one shard at a time. Each shard is copied
+// under its read lock, so what you see of a shard is a consistent point in
+// time, and the loop body is free to use the map. Different shards are
+// copied at different times.
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		type kv struct {
+			k K
+			v V
+		}
+		var buf []kv
+		for i := range m.shards {
+			s := &m.shards[i]
+			buf = buf[:0]
+			s.mu.RLock()
+			for k, v := range s.m {
+				buf = append(buf, kv{k, v})
+			}
+			s.mu.RUnlock()
+			for _, e := range buf {
+				if !yield(e.k, e.v) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package shardmap_test
+
+import (
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/midsane/go-playground/07-concurrency/shardmap"
+)
+
+const goroutines, rounds = 16, 500
+
+func newMap() *shardmap.Map[string, int] {
+	return shardmap.New[string, int](shardmap.Options[string]{})
+}
+
+func TestShardsRoundUp(t *testing.T) {
+	for n, want := range map[int]int{1: 1, 3: 4, 16: 16, 17: 32} {
+		if got := shardmap.New[int, int](shardmap.Options[int]{Shards: n}).Shards(); got != want {
+			t.Errorf("Shards: %d = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestBasics(t *testing.T) {
+	m := newMap()
+	m.Set("a", 1)
+	m.Set("b", 2)
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Errorf("Get = %d, %v", v, ok)
+	}
+	if !m.Delete("a") || m.Delete("a") {
+		t.Error("Delete didn't report presence")
+	}
+	m.Set("c", 3)
+	if got := maps.Collect(m.All()); !maps.Equal(got, map[string]int{"b": 2, "c": 3}) || m.Len() != 2 {
+		t.Errorf("All = %v, Len = %d", got, m.Len())
+	}
+	m.Clear()
+	if m.Len() != 0 {
+		t.Errorf("Len after Clear = %d", m.Len())
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	m := newMap()
+	if v, loaded := m.GetOrSet("k", 1); loaded || v != 1 {
+		t.Errorf("first GetOrSet = %d, %v", v, loaded)
+	}
+	if v, loaded := m.GetOrSet("k", 2); !loaded || v != 1 {
+		t.Errorf("second GetOrSet = %d, %v", v, loaded)
+	}
+}
+
+func TestGetOrSetConcurrent(t *testing.T) {
+	m := newMap()
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stored  int
+		results = make(map[int]bool)
+	)
+	for g := range goroutines {
+		wg.Go(func() {
+			v, loaded := m.GetOrSet("k", g)
+			mu.Lock()
+			defer mu.Unlock()
+			if !loaded {
+				stored++
+			}
+			results[v] = true
+		})
+	}
+	wg.Wait()
+	if stored != 1 || len(results) != 1 {
+		t.Errorf("%d goroutines stored, %d different values seen", stored, len(results))
+	}
+}
+
+func TestCompute(t *testing.T) {
+	m := newMap()
+	inc := func(old int, exists bool) (int, bool) { return old + 1, true }
+	if v, ok := m.Compute("n", inc); !ok || v != 1 {
+		t.Errorf("Compute on a missing key = %d, %v", v, ok)
+	}
+	m.Compute("n", inc)
+	if v, _ := m.Get("n"); v != 2 {
+		t.Errorf("n = %d", v)
+	}
+	if _, ok := m.Compute("n", func(int, bool) (int, bool) { return 0, false }); ok {
+		t.Error("keep == false reported a value")
+	}
+	if _, ok := m.Get("n"); ok {
+		t.Error("keep == false didn't delete")
+	}
+}
+
+func TestComputeConcurrent(t *testing.T) {
+	m := newMap()
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for range rounds {
+				m.Compute("n", func(old int, _ bool) (int, bool) { return old + 1, true })
+			}
+		})
+	}
+	wg.Wait()
+	if v, _ := m.Get("n"); v != goroutines*rounds {
+		t.Errorf("n = %d, want %d: increments were lost", v, goroutines*rounds)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	m := newMap()
+	if m.CompareAndSwap("k", 0, 1) {
+		t.Error("CAS on a missing key succeeded")
+	}
+	m.Set("k", 1)
+	if m.CompareAndSwap("k", 2, 3) {
+		t.Error("CAS with the wrong old value succeeded")
+	}
+	if !m.CompareAndSwap("k", 1, 2) {
+		t.Error("CAS with the right old value failed")
+	}
+	if m.CompareAndDelete("k", 1) || !m.CompareAndDelete("k", 2) {
+		t.Error("CompareAndDelete compared wrong")
+	}
+	if _, ok := m.Get("k"); ok {
+		t.Error("CompareAndDelete left the key")
+	}
+
+	// with a V that isn't comparable it panics, like sync.Map
+	s := shardmap.New[string, []int](shardmap.Options[string]{})
+	s.Set("k", nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("CAS on a slice value didn't panic")
+		}
+	}()
+	s.CompareAndSwap("k", nil, []int{1})
+}
+
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	m := newMap()
+	m.Set("n", 0)
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for range rounds {
+				for {
+					old, _ := m.Get("n")
+					if m.CompareAndSwap("n", old, old+1) {
+						break
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if v, _ := m.Get("n"); v != goroutines*rounds {
+		t.Errorf("n = %d, want %d: a CAS went through on a stale value", v, goroutines*rounds)
+	}
+}
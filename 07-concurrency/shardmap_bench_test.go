@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/shardmap"
+)
+
+/*
+MidCache keeps its map behind one lock, which queues every goroutine on it,
+even readers once a writer is waiting. shardmap splits the map into shards
+with their own locks; sync.Map is tuned for keys written once and read many
+times. each gets a read-heavy and a write-heavy mix:
+
+	go test -run '^$' -bench . -cpu 1,4,16 ./07-concurrency
+
+contention is the whole point, so compare the -cpu > 1 rows.
+*/
+
+const benchKeys = 1 << 10
+
+type store interface {
+	get(k int)
+	set(k, v int)
+}
+
+type midCache struct{ m *MidCache }
+
+func (s midCache) get(k int)    { s.m.Get(k) }
+func (s midCache) set(k, v int) { s.m.Set(k, v) }
+
+type syncMap struct{ m sync.Map }
+
+func (s *syncMap) get(k int)    { s.m.Load(k) }
+func (s *syncMap) set(k, v int) { s.m.Store(k, v) }
+
+type shardMap struct{ m *shardmap.Map[int, int] }
+
+func (s shardMap) get(k int)    { s.m.Get(k) }
+func (s shardMap) set(k, v int) { s.m.Set(k, v) }
+
+func benchMix(b *testing.B, s store, writePerc int) {
+	for k := range benchKeys {
+		s.set(k, k)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// cheap per-goroutine PRNG so math/rand's lock isn't what we measure
+		x := uint32(time.Now().UnixNano()) | 1
+		for pb.Next() {
+			x ^= x << 13
+			x ^= x >> 17
+			x ^= x << 5
+			k := int(x % benchKeys)
+			if int((x>>16)%100) < writePerc {
+				s.set(k, k)
+			} else {
+				s.get(k)
+			}
+		}
+	})
+}
+
+func BenchmarkMidCacheReadHeavy(b *testing.B) {
+	benchMix(b, midCache{&MidCache{data: make(map[int]int)}}, 10)
+}
+
+func BenchmarkMidCacheWriteHeavy(b *testing.B) {
+	benchMix(b, midCache{&MidCache{data: make(map[int]int)}}, 90)
+}
+
+func BenchmarkSyncMapReadHeavy(b *testing.B) {
+	benchMix(b, &syncMap{}, 10)
+}
+
+func BenchmarkSyncMapWriteHeavy(b *testing.B) {
+	benchMix(b, &syncMap{}, 90)
+}
+
+func BenchmarkShardMapReadHeavy(b *testing.B) {
+	benchMix(b, shardMap{shardmap.New[int, int](shardmap.Options[int]{})}, 10)
+}
+
+func BenchmarkShardMapWriteHeavy(b *testing.B) {
+	benchMix(b, shardMap{shardmap.New[int, int](shardmap.Options[int]{})}, 90)
+}
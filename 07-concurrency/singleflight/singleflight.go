@@ -0,0 +1,142 @@
+// Package singleflight collapses concurrent calls for the same key into
+// one, e.g. so a hot record missing from the cache is loaded from the
+// store once rather than once per request.
+//
+// Differences from golang.org/x/sync/singleflight: it's generic, the
+// result can be reused for a short while after the call returns, a caller
+// whose context ends stops waiting without affecting the others, and a
+// panic in the shared call reaches every caller instead of just one.
+package singleflight
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// Group deduplicates calls by key. The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	// ShareFor keeps a finished call's result for callers arriving within
+	// this long after it returned. Zero shares only while in flight. Errors
+	// are shared the same way, which also damps retries against a failing
+	// store.
+	ShareFor time.Duration
+	// Clock times ShareFor; nil means the system clock.
+	Clock clock.Clock
+
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+type call[V any] struct {
+	done    chan struct{}
+	val     V
+	err     error
+	panic   *safego.PanicError
+	expires time.Time // set on completion when shared afterwards
+
+	// waiters still interested; the call's context is canceled when it
+	// drops to zero before fn returns
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do calls fn once per key at a time. Callers that arrive while it runs,
+// or within ShareFor after, get the same result, and shared reports that
+// someone else's call produced it.
+//
+// fn gets a context that keeps ctx's values but not its cancellation: it's
+// canceled only once every caller waiting for it has given up. If ctx ends
+// first, Do returns ctx's error and leaves fn running for the others.
+//
+// If fn panics, Do panics in every caller with a *safego.PanicError
+// carrying the original value and stack.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(context.Context) (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*call[V])
+	}
+	c, ok := g.calls[key]
+	if ok && !c.expires.IsZero() && !clock.Or(g.Clock).Now().Before(c.expires) {
+		delete(g.calls, key)
+		ok = false
+	}
+	if ok {
+		c.waiters++
+		g.mu.Unlock()
+		return g.wait(ctx, key, c, true)
+	}
+
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c = &call[V]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	go g.run(callCtx, key, c, fn)
+	return g.wait(ctx, key, c, false)
+}
+
+func (g *Group[K, V]) run(ctx context.Context, key K, c *call[V], fn func(context.Context) (V, error)) {
+	defer c.cancel()
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				c.panic = &safego.PanicError{Value: r, Stack: debug.Stack()}
+			}
+		}()
+		c.val, c.err = fn(ctx)
+	}()
+
+	g.mu.Lock()
+	if g.ShareFor > 0 && g.calls[key] == c {
+		clk := clock.Or(g.Clock)
+		c.expires = clk.Now().Add(g.ShareFor)
+		clk.AfterFunc(g.ShareFor, func() { g.forgetCall(key, c) })
+	} else if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(c.done)
+}
+
+func (g *Group[K, V]) wait(ctx context.Context, key K, c *call[V], shared bool) (V, error, bool) {
+	select {
+	case <-c.done:
+		if c.panic != nil {
+			panic(c.panic)
+		}
+		return c.val, c.err, shared
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 && c.expires.IsZero() && g.calls[key] == c {
+			// nobody wants it any more; later callers start afresh rather
+			// than joining a canceled call
+			c.cancel()
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		var zero V
+		return zero, ctx.Err(), shared
+	}
+}
+
+// Forget drops key's in-flight or shared call, so the next Do runs fn
+// again. Callers already waiting still get the old call's result.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
+
+func (g *Group[K, V]) forgetCall(key K, c *call[V]) {
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+}
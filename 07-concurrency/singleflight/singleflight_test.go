@@ -0,0 +1,236 @@
+package singleflight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/clock"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// waitForWaiters blocks until n callers are waiting on key's call.
+func waitForWaiters[K comparable, V any](t *testing.T, g *Group[K, V], key K, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		g.mu.Lock()
+		c := g.calls[key]
+		got := c != nil && c.waiters >= n
+		g.mu.Unlock()
+		if got {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("never saw %d waiters on %v", n, key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type result struct {
+	v      int
+	err    error
+	shared bool
+}
+
+func TestDeduplicatesConcurrentCalls(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 10
+	results := make(chan result, callers)
+	for range callers {
+		go func() {
+			v, err, shared := g.Do(context.Background(), "user:1", fn)
+			results <- result{v, err, shared}
+		}()
+	}
+	waitForWaiters(t, &g, "user:1", callers)
+	close(release)
+
+	shared := 0
+	for range callers {
+		r := <-results
+		if r.v != 42 || r.err != nil {
+			t.Errorf("Do = %d, %v", r.v, r.err)
+		}
+		if r.shared {
+			shared++
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times", n)
+	}
+	if shared != callers-1 {
+		t.Errorf("%d shared results, want %d", shared, callers-1)
+	}
+
+	// finished and not shared afterwards: the next call runs fn again
+	if _, _, shared := g.Do(context.Background(), "user:1", fn); shared || calls.Load() != 2 {
+		t.Errorf("Do after the call finished: shared %v, %d calls", shared, calls.Load())
+	}
+}
+
+func TestKeysAreIndependent(t *testing.T) {
+	var g Group[int, int]
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for k := range 3 {
+		wg.Go(func() {
+			if v, _, shared := g.Do(context.Background(), k, func(context.Context) (int, error) {
+				<-release
+				return k, nil
+			}); v != k || shared {
+				t.Errorf("key %d: got %d, shared %v", k, v, shared)
+			}
+		})
+	}
+	for k := range 3 {
+		waitForWaiters(t, &g, k, 1)
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestForget(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	old := make(chan int)
+	go func() {
+		v, _, _ := g.Do(context.Background(), "k", func(context.Context) (int, error) {
+			<-release
+			return 1, nil
+		})
+		old <- v
+	}()
+	waitForWaiters(t, &g, "k", 1)
+
+	g.Forget("k")
+	v, _, shared := g.Do(context.Background(), "k", func(context.Context) (int, error) { return 2, nil })
+	if v != 2 || shared {
+		t.Errorf("Do after Forget = %d, shared %v; want a fresh call", v, shared)
+	}
+	close(release)
+	if v := <-old; v != 1 {
+		t.Errorf("caller of the forgotten call got %d", v)
+	}
+}
+
+func TestShareFor(t *testing.T) {
+	f := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	g := Group[string, int]{ShareFor: time.Second, Clock: f}
+	calls := 0
+	boom := errors.New("store down")
+	fn := func(context.Context) (int, error) {
+		calls++
+		return calls, boom
+	}
+
+	g.Do(context.Background(), "k", fn)
+	f.Advance(time.Second - time.Nanosecond)
+	if v, err, shared := g.Do(context.Background(), "k", fn); v != 1 || err != boom || !shared {
+		t.Errorf("within ShareFor = %d, %v, %v; want the first result, error included", v, err, shared)
+	}
+	f.Advance(time.Nanosecond)
+	if v, _, shared := g.Do(context.Background(), "k", fn); v != 2 || shared {
+		t.Errorf("after ShareFor = %d, shared %v", v, shared)
+	}
+
+	g.Forget("k")
+	if v, _, _ := g.Do(context.Background(), "k", fn); v != 3 {
+		t.Errorf("after Forget = %d, want a fresh call", v)
+	}
+}
+
+func TestCallerGivingUp(t *testing.T) {
+	var g Group[string, int]
+	fnCtx := make(chan context.Context, 1)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		fnCtx <- ctx
+		select {
+		case <-release:
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+
+	patient := make(chan result)
+	go func() {
+		v, err, shared := g.Do(context.Background(), "k", fn)
+		patient <- result{v, err, shared}
+	}()
+	waitForWaiters(t, &g, "k", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	impatient := make(chan error)
+	go func() {
+		_, err, _ := g.Do(ctx, "k", fn)
+		impatient <- err
+	}()
+	waitForWaiters(t, &g, "k", 2)
+
+	cancel()
+	if err := <-impatient; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller = %v", err)
+	}
+	shared := <-fnCtx
+	if shared.Err() != nil {
+		t.Fatal("one caller giving up canceled the shared call")
+	}
+	close(release)
+	if r := <-patient; r.v != 1 || r.err != nil {
+		t.Errorf("remaining caller = %+v", r)
+	}
+
+	// when the last caller gives up, fn's context is canceled
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err, _ := g.Do(ctx, "last", fn)
+		done <- err
+	}()
+	lonely := <-fnCtx
+	cancel()
+	<-done
+	select {
+	case <-lonely.Done():
+	case <-time.After(time.Second):
+		t.Error("fn's context outlived every caller")
+	}
+}
+
+func TestPanicReachesEveryCaller(t *testing.T) {
+	var g Group[string, int]
+	release := make(chan struct{})
+	fn := func(context.Context) (int, error) {
+		<-release
+		panic("boom")
+	}
+	const callers = 3
+	panics := make(chan any, callers)
+	for range callers {
+		go func() {
+			defer func() { panics <- recover() }()
+			g.Do(context.Background(), "k", fn)
+		}()
+	}
+	waitForWaiters(t, &g, "k", callers)
+	close(release)
+	for range callers {
+		perr, ok := (<-panics).(*safego.PanicError)
+		if !ok || perr.Value != "boom" {
+			t.Errorf("recovered %v, want a *safego.PanicError", perr)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
+	"cmp"
+	"context"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
+
+	"github.com/midsane/go-playground/04-logging/audit"
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+	"github.com/midsane/go-playground/06-context/deadline"
+	"github.com/midsane/go-playground/08-http-server/httpx"
+	httpserver "github.com/midsane/go-playground/08-http-server/server"
 )
 
 type User struct {
@@ -18,6 +24,8 @@ type User struct {
 }
 
 
+var errUserNotFound = apperr.New(apperr.NotFound, "user not found")
+
 type userStore struct {
 	mu    sync.Mutex
 	users map[int]User
@@ -65,7 +73,7 @@ func (s *userStore) Update(id int, u User) (User, error) {
 	defer s.mu.Unlock()
 
 	if _, ok := s.users[id]; !ok {
-		return User{}, errors.New("not found")
+		return User{}, errUserNotFound
 	}
 	u.ID = id
 	s.users[id] = u
@@ -84,114 +92,126 @@ func (s *userStore) Delete(id int) bool {
 }
 
 
-func writeJSON(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(v)
-}
-
-func readJSON(r *http.Request, dst any) error {
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	return dec.Decode(dst)
-}
-
-func parseID(path string) (int, error) {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) != 2 {
-		return 0, errors.New("invalid path")
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperr.KindOf(err) == apperr.Internal {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
 	}
-	return strconv.Atoi(parts[1])
+	apperr.Write(w, r, err)
 }
 
 type server struct {
 	store *userStore
+	audit *audit.Logger
 }
 
-func (s *server) users(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		users := s.store.GetAll()
-		writeJSON(w, http.StatusOK, users)
-
-	case http.MethodPost:
-		var u User
-		if err := readJSON(r, &u); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if u.Name == "" || u.Email == "" {
-			http.Error(w, "name and email required", http.StatusBadRequest)
-			return
-		}
-		created := s.store.Create(u)
-		writeJSON(w, http.StatusCreated, created)
+func (s *server) listUsers(w http.ResponseWriter, r *http.Request) {
+	httpx.WriteJSON(w, http.StatusOK, s.store.GetAll())
+}
 
-	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
+	var u User
+	if err := httpx.DecodeJSON(w, r, &u); err != nil {
+		writeError(w, r, err)
+		return
 	}
+	if u.Name == "" || u.Email == "" {
+		writeError(w, r, apperr.New(apperr.Invalid, "name and email required"))
+		return
+	}
+	created := s.store.Create(u)
+	httpx.WriteJSON(w, http.StatusCreated, created)
 }
 
-func (s *server) userByID(w http.ResponseWriter, r *http.Request) {
-	id, err := parseID(r.URL.Path)
+func (s *server) getUser(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.PathInt(r, "id")
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
+	u, ok := s.store.Get(id)
+	if !ok {
+		writeError(w, r, errUserNotFound)
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, u)
+}
 
-	switch r.Method {
-	case http.MethodGet:
-		u, ok := s.store.Get(id)
-		if !ok {
-			http.NotFound(w, r)
-			return
-		}
-		writeJSON(w, http.StatusOK, u)
-
-	case http.MethodPut:
-		var u User
-		if err := readJSON(r, &u); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		updated, err := s.store.Update(id, u)
-		if err != nil {
-			http.NotFound(w, r)
-			return
-		}
-		writeJSON(w, http.StatusOK, updated)
-
-	case http.MethodDelete:
-		if !s.store.Delete(id) {
-			http.NotFound(w, r)
-			return
-		}
-		w.WriteHeader(http.StatusNoContent)
+func (s *server) updateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.PathInt(r, "id")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var u User
+	if err := httpx.DecodeJSON(w, r, &u); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	updated, err := s.store.Update(id, u)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, updated)
+}
 
-	default:
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func (s *server) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := httpx.PathInt(r, "id")
+	if err != nil {
+		writeError(w, r, err)
+		return
 	}
+	if !s.store.Delete(id) {
+		writeError(w, r, errUserNotFound)
+		return
+	}
+	if err := s.audit.Record(audit.Event{
+		Action:  "user.delete",
+		Target:  strconv.Itoa(id),
+		Outcome: audit.Success,
+		Fields:  map[string]any{"remote_addr": r.RemoteAddr},
+	}); err != nil {
+		log.Println("audit:", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 
-func logging(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Println(r.Method, r.URL.Path, time.Since(start))
-	})
-}
+// AUDIT_LOG overrides it; the default is this binary's own so it never
+// shares a chain with another server started in the same directory
+const defaultAuditLog = "basic_server-audit.log"
 
 func main() {
 	store := newUserStore()
-	srv := &server{store: store}
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/users", srv.users)
-	mux.HandleFunc("/users/", srv.userByID)
-
-	handler := logging(mux)
-
-	log.Println("listening on :8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	al, err := audit.Open(cmp.Or(os.Getenv("AUDIT_LOG"), defaultAuditLog))
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &server{store: store, audit: al}
+	ready := &httpserver.Readiness{}
+
+	// wrong methods get a 405 with Allow, bad ids a 400, from the router and
+	// httpx.PathInt rather than each handler
+	mux := httpx.NewRouter()
+	mux.Handle("GET /readyz", ready)
+	mux.HandleFunc("GET /users", srv.listUsers)
+	mux.HandleFunc("POST /users", srv.createUser)
+	mux.HandleFunc("GET /users/{id}", srv.getUser)
+	mux.HandleFunc("PUT /users/{id}", srv.updateUser)
+	mux.HandleFunc("DELETE /users/{id}", srv.deleteUser)
+
+	handler := httpx.NewChain().
+		Use("request-id", httpx.RequestID).
+		Use("log", httpx.AccessLog(httpx.AccessLogOptions{})).
+		Use("deadline", deadline.Middleware(deadline.Policy{Default: 10 * time.Second, Max: 30 * time.Second})).
+		Then(mux)
+
+	// a request can run for up to 30s (the deadline cap), give it that long to finish
+	os.Exit(httpserver.Run(context.Background(), &http.Server{Addr: ":8080", Handler: handler}, httpserver.Options{
+		ShutdownTimeout: 30 * time.Second,
+		Ready:           ready,
+		Hooks: []httpserver.Hook{
+			{Name: "audit log", Fn: func(context.Context) error { return al.Close() }},
+		},
+	}))
 }
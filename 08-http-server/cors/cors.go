@@ -0,0 +1,256 @@
+// Package cors lets browsers call an API from other origins.
+//
+// The browser does the enforcing: it sends Origin, and for anything that
+// isn't a simple GET/HEAD/POST it first asks with an OPTIONS preflight
+// whether the real request is allowed. This package answers preflights
+// itself and adds the Access-Control-* headers to real responses; a
+// request from an origin that isn't allowed still reaches the handler, it
+// just gets no CORS headers, so the browser won't hand the response to
+// the page.
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config says who may call and how. The zero value allows nothing.
+type Config struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"),
+	// subdomain wildcards ("https://*.example.com", which matches any depth
+	// of subdomain but not example.com itself) or "*" for every origin.
+	// Matching ignores case.
+	AllowedOrigins []string
+	// AllowedOriginPatterns are regular expressions an origin must match as
+	// a whole, e.g. `https://pr-\d+\.preview\.example\.com`. They are
+	// case-insensitive like the other origin matching.
+	AllowedOriginPatterns []string
+	// AllowedMethods are the methods a preflight may ask for, GET, HEAD and
+	// POST if empty. They are upper-cased, so "get" in a config file works;
+	// the method a browser asks for is compared as sent.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a preflight may ask for,
+	// matched ignoring case. "*" allows any.
+	AllowedHeaders []string
+	// ExposedHeaders are response headers page scripts may read besides the
+	// safelisted ones (Content-Type, Cache-Control, ...).
+	ExposedHeaders []string
+	// AllowCredentials lets requests carry cookies and HTTP auth. It can't
+	// be combined with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long a browser may cache a preflight answer, rounded
+	// down to seconds. 0 leaves it to the browser (5s in most), a negative
+	// value turns caching off. Browsers cap it, Chrome at 2h.
+	MaxAge time.Duration
+}
+
+// CORS is the middleware built from a Config.
+type CORS struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []wildcard
+	patterns    []*regexp.Regexp
+	methods     []string
+	anyHeader   bool
+	headers     map[string]bool
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+// a subdomain wildcard split at its "*"
+type wildcard struct {
+	prefix, suffix string
+}
+
+func (w wildcard) match(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) ||
+		!strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	// what the * covers has to be host labels; anything else could move the
+	// suffix out of the host, e.g. https://evil.com/.example.com
+	sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	return !strings.HasPrefix(sub, ".") && !strings.ContainsFunc(sub, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.')
+	})
+}
+
+// New checks cfg and builds the middleware.
+func New(cfg Config) (*CORS, error) {
+	c := &CORS{
+		origins:     make(map[string]bool),
+		headers:     make(map[string]bool),
+		credentials: cfg.AllowCredentials,
+	}
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(strings.TrimSpace(o))
+		switch i := strings.IndexByte(o, '*'); {
+		case o == "*":
+			c.anyOrigin = true
+		case i < 0:
+			c.origins[o] = true
+		case strings.Count(o, "*") > 1 || !strings.HasSuffix(o[:i], "://") || !strings.HasPrefix(o[i+1:], "."):
+			return nil, fmt.Errorf("cors: origin %q: a wildcard has to be a whole leading subdomain, like https://*.example.com", o)
+		default:
+			c.wildcards = append(c.wildcards, wildcard{prefix: o[:i], suffix: o[i+1:]})
+		}
+	}
+	if c.anyOrigin && c.credentials {
+		// the spec forbids "*" with credentials; echoing every origin back
+		// instead would let any site make logged-in requests as the user
+		return nil, errors.New("cors: AllowCredentials can't be used with the * origin, list the origins")
+	}
+	for _, p := range cfg.AllowedOriginPatterns {
+		re, err := regexp.Compile(`(?i)^(?:` + p + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("cors: origin pattern %q: %w", p, err)
+		}
+		c.patterns = append(c.patterns, re)
+	}
+
+	for _, m := range cfg.AllowedMethods {
+		c.methods = append(c.methods, strings.ToUpper(strings.TrimSpace(m)))
+	}
+	if len(c.methods) == 0 {
+		c.methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	c.exposed = strings.Join(cfg.ExposedHeaders, ", ")
+	switch {
+	case cfg.MaxAge < 0:
+		c.maxAge = "0"
+	case cfg.MaxAge >= time.Second:
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	}
+	return c, nil
+}
+
+// Handler wraps next; it fits httpx.Chain.Use as c.Handler.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
+			return
+		}
+		c.varyOrigin(w.Header())
+		if origin != "" && c.allowOrigin(origin) {
+			h := w.Header()
+			c.setOrigin(h, origin)
+			if c.exposed != "" {
+				h.Set("Access-Control-Expose-Headers", c.exposed)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers without calling the handler. A refusal is the same
+// 204 without the Access-Control-Allow-* headers; the browser reports the
+// reason in the console, there's no need to tell other sites which
+// origins, methods or headers would pass.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	// caches must key the answer on everything it was computed from
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if !c.allowOrigin(origin) {
+		return
+	}
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !slices.Contains(c.methods, method) {
+		return
+	}
+	requested, ok := c.allowHeaders(r.Header.Values("Access-Control-Request-Headers"))
+	if !ok {
+		return
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", method)
+	if requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
+
+// allowHeaders checks every header of the comma separated
+// Access-Control-Request-Headers lists and returns them, normalized, to
+// echo back. Echoing instead of sending "*" works with credentials too.
+func (c *CORS) allowHeaders(lists []string) (string, bool) {
+	var names []string
+	for _, list := range lists {
+		for name := range strings.SplitSeq(list, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !c.anyHeader && !c.headers[name] {
+				return "", false
+			}
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", "), true
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if c.origins[lower] {
+		return true
+	}
+	for _, w := range c.wildcards {
+		if w.match(lower) {
+			return true
+		}
+	}
+	// patterns see the origin as sent, (?i) takes care of case
+	for _, re := range c.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// varyOrigin marks responses that depend on Origin, which is all of them
+// unless every origin gets the same "*". It's set even without an Origin
+// header: a cache that stored the no-CORS answer would otherwise hand it
+// to browsers that need the headers.
+func (c *CORS) varyOrigin(h http.Header) {
+	if !c.anyOrigin {
+		h.Add("Vary", "Origin")
+	}
+}
@@ -0,0 +1,285 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testConfig = Config{
+	AllowedOrigins:        []string{"https://app.example.com", "https://*.example.org"},
+	AllowedOriginPatterns: []string{`https://pr-\d+\.preview\.example\.net`},
+	AllowedMethods:        []string{http.MethodGet, http.MethodPut},
+	AllowedHeaders:        []string{"Authorization", "Content-Type"},
+	ExposedHeaders:        []string{"X-Request-ID"},
+	AllowCredentials:      true,
+	MaxAge:                90 * time.Second,
+}
+
+// serve runs one request through cfg. reached reports whether the
+// wrapped handler ran.
+func serve(t *testing.T, cfg Config, method string, headers map[string]string) (rec *httptest.ResponseRecorder, reached bool) {
+	t.Helper()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	r := httptest.NewRequest(method, "/users/1", nil)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec, reached
+}
+
+func preflight(origin, method, headers string) map[string]string {
+	h := map[string]string{"Origin": origin, "Access-Control-Request-Method": method}
+	if headers != "" {
+		h["Access-Control-Request-Headers"] = headers
+	}
+	return h
+}
+
+func assertNoCORS(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	for k := range rec.Header() {
+		if strings.HasPrefix(k, "Access-Control-") {
+			t.Errorf("unexpected %s: %q", k, rec.Header().Get(k))
+		}
+	}
+}
+
+func assertVary(t *testing.T, rec *httptest.ResponseRecorder, want ...string) {
+	t.Helper()
+	got := rec.Header().Values("Vary")
+	for _, w := range want {
+		if !slices.Contains(got, w) {
+			t.Errorf("Vary %q is missing %q", got, w)
+		}
+	}
+}
+
+func TestPreflightAllowed(t *testing.T) {
+	rec, reached := serve(t, testConfig, http.MethodOptions,
+		preflight("https://app.example.com", http.MethodPut, "content-type, Authorization"))
+	if reached {
+		t.Error("preflight reached the handler")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status %d, want 204", rec.Code)
+	}
+	h := rec.Header()
+	for k, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "PUT",
+		"Access-Control-Allow-Headers":     "content-type, authorization",
+		"Access-Control-Max-Age":           "90",
+	} {
+		if got := h.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	assertVary(t, rec, "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+}
+
+func TestPreflightRefused(t *testing.T) {
+	for name, headers := range map[string]map[string]string{
+		"disallowed origin":        preflight("https://evil.com", http.MethodGet, ""),
+		"null origin":              preflight("null", http.MethodGet, ""),
+		"disallowed method":        preflight("https://app.example.com", http.MethodDelete, ""),
+		"method case":              preflight("https://app.example.com", "put", ""),
+		"disallowed header":        preflight("https://app.example.com", http.MethodPut, "Content-Type, X-Evil"),
+		"bare wildcard domain":     preflight("https://example.org", http.MethodGet, ""),
+		"wildcard suffix trick":    preflight("https://evilexample.org", http.MethodGet, ""),
+		"wildcard path trick":      preflight("https://evil.com/.example.org", http.MethodGet, ""),
+		"pattern is anchored":      preflight("https://pr-1.preview.example.net.evil.com", http.MethodGet, ""),
+		"scheme must match":        preflight("http://app.example.com", http.MethodGet, ""),
+		"port must match":          preflight("https://app.example.com:8443", http.MethodGet, ""),
+		"wildcard needs subdomain": preflight("https://.example.org", http.MethodGet, ""),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec, reached := serve(t, testConfig, http.MethodOptions, headers)
+			if reached {
+				t.Error("preflight reached the handler")
+			}
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status %d, want 204", rec.Code)
+			}
+			assertNoCORS(t, rec)
+			assertVary(t, rec, "Origin")
+		})
+	}
+}
+
+func TestOriginMatching(t *testing.T) {
+	for _, origin := range []string{
+		"https://app.example.com",
+		"https://APP.Example.com",
+		"https://a.example.org",
+		"https://a.b.example.org",
+		"https://pr-12.preview.example.net",
+		"https://PR-12.Preview.Example.net",
+	} {
+		rec, _ := serve(t, testConfig, http.MethodOptions, preflight(origin, http.MethodGet, ""))
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", origin, got)
+		}
+	}
+}
+
+func TestNullOriginOnlyWhenListed(t *testing.T) {
+	cfg := testConfig
+	cfg.AllowedOrigins = []string{"null"}
+	rec, _ := serve(t, cfg, http.MethodOptions, preflight("null", http.MethodGet, ""))
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "null" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want null", got)
+	}
+}
+
+func TestCredentialsWithWildcard(t *testing.T) {
+	if _, err := New(Config{AllowedOrigins: []string{"*"}, AllowCredentials: true}); err == nil {
+		t.Error("* with credentials was accepted")
+	}
+
+	// without credentials * is fine, and then nothing varies by Origin
+	cfg := Config{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}
+	rec, _ := serve(t, cfg, http.MethodOptions, preflight("https://any.site", http.MethodGet, "X-Anything"))
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Origin") != "*" || h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("got origin %q credentials %q", h.Get("Access-Control-Allow-Origin"), h.Get("Access-Control-Allow-Credentials"))
+	}
+	if got := h.Get("Access-Control-Allow-Headers"); got != "x-anything" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+	rec, _ = serve(t, cfg, http.MethodGet, map[string]string{"Origin": "https://any.site"})
+	if v := rec.Header().Values("Vary"); len(v) != 0 {
+		t.Errorf("Vary = %q on a * response", v)
+	}
+}
+
+func TestBadConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{AllowedOrigins: []string{"https://a.*.example.com"}},
+		{AllowedOrigins: []string{"https://*example.com"}},
+		{AllowedOrigins: []string{"*.example.com"}},
+		{AllowedOriginPatterns: []string{"("}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%+v was accepted", cfg)
+		}
+	}
+}
+
+func TestVaryOriginOnEveryResponse(t *testing.T) {
+	for name, headers := range map[string]map[string]string{
+		"allowed":    {"Origin": "https://app.example.com"},
+		"disallowed": {"Origin": "https://evil.com"},
+		"no origin":  {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec, reached := serve(t, testConfig, http.MethodGet, headers)
+			if !reached {
+				t.Error("request didn't reach the handler")
+			}
+			assertVary(t, rec, "Origin")
+		})
+	}
+}
+
+func TestActualRequest(t *testing.T) {
+	rec, _ := serve(t, testConfig, http.MethodGet, map[string]string{"Origin": "https://app.example.com"})
+	h := rec.Header()
+	if h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" ||
+		h.Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Errorf("headers %v", h)
+	}
+	if h.Get("Access-Control-Allow-Methods") != "" || h.Get("Access-Control-Max-Age") != "" {
+		t.Errorf("preflight-only headers on an actual request: %v", h)
+	}
+
+	rec, _ = serve(t, testConfig, http.MethodGet, map[string]string{"Origin": "https://evil.com"})
+	assertNoCORS(t, rec)
+}
+
+func TestNonPreflightOptions(t *testing.T) {
+	// OPTIONS without Access-Control-Request-Method, or without Origin, is
+	// an ordinary request for the handler
+	for name, headers := range map[string]map[string]string{
+		"no request method": {"Origin": "https://app.example.com"},
+		"no origin":         {"Access-Control-Request-Method": http.MethodPut},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec, reached := serve(t, testConfig, http.MethodOptions, headers)
+			if !reached {
+				t.Error("request didn't reach the handler")
+			}
+			if rec.Header().Get("Access-Control-Allow-Methods") != "" {
+				t.Error("answered as a preflight")
+			}
+		})
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	for _, tc := range []struct {
+		maxAge time.Duration
+		want   string
+	}{
+		{0, ""},
+		{-1, "0"},
+		{1500 * time.Millisecond, "1"},
+		{2 * time.Hour, "7200"},
+	} {
+		cfg := testConfig
+		cfg.MaxAge = tc.maxAge
+		rec, _ := serve(t, cfg, http.MethodOptions, preflight("https://app.example.com", http.MethodGet, ""))
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != tc.want {
+			t.Errorf("MaxAge %v: got %q, want %q", tc.maxAge, got, tc.want)
+		}
+	}
+}
+
+func TestMethodsFromConfigAreUpperCased(t *testing.T) {
+	cfg := testConfig
+	cfg.AllowedMethods = []string{"get", " delete "}
+	rec, _ := serve(t, cfg, http.MethodOptions, preflight("https://app.example.com", http.MethodDelete, ""))
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "DELETE" {
+		t.Errorf("Access-Control-Allow-Methods = %q, want DELETE", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	defaults := Config{AllowedOrigins: []string{"http://localhost:3000"}, AllowedMethods: []string{"GET"}, MaxAge: time.Minute}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://*.example.org,")
+	t.Setenv("CORS_EXPOSED_HEADERS", "")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "2h")
+	cfg, err := FromEnv(defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.AllowedOrigins, []string{"https://a.example.com", "https://*.example.org"}) {
+		t.Errorf("AllowedOrigins = %q", cfg.AllowedOrigins)
+	}
+	if !slices.Equal(cfg.AllowedMethods, []string{"GET"}) {
+		t.Errorf("unset variable replaced the default: AllowedMethods = %q", cfg.AllowedMethods)
+	}
+	if !cfg.AllowCredentials || cfg.MaxAge != 2*time.Hour || cfg.ExposedHeaders != nil {
+		t.Errorf("got %+v", cfg)
+	}
+
+	t.Setenv("CORS_MAX_AGE", "ten minutes")
+	if _, err := FromEnv(defaults); err == nil {
+		t.Error("bad CORS_MAX_AGE accepted")
+	}
+}
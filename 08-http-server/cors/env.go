@@ -0,0 +1,62 @@
+package cors
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromEnv returns c with every field that has a CORS_* variable set
+// replaced by it, so a server keeps its defaults in code and deployments
+// override only what differs. The names and formats are the ones
+// 03-config-management's Config reads:
+//
+//	CORS_ALLOWED_ORIGINS          comma-separated list
+//	CORS_ALLOWED_ORIGIN_PATTERNS  comma-separated list
+//	CORS_ALLOWED_METHODS          comma-separated list
+//	CORS_ALLOWED_HEADERS          comma-separated list
+//	CORS_EXPOSED_HEADERS          comma-separated list
+//	CORS_ALLOW_CREDENTIALS        true or false
+//	CORS_MAX_AGE                  a time.Duration, like 10m
+//
+// A variable set to the empty string empties the list.
+func FromEnv(c Config) (Config, error) {
+	for name, field := range map[string]*[]string{
+		"CORS_ALLOWED_ORIGINS":         &c.AllowedOrigins,
+		"CORS_ALLOWED_ORIGIN_PATTERNS": &c.AllowedOriginPatterns,
+		"CORS_ALLOWED_METHODS":         &c.AllowedMethods,
+		"CORS_ALLOWED_HEADERS":         &c.AllowedHeaders,
+		"CORS_EXPOSED_HEADERS":         &c.ExposedHeaders,
+	} {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = splitList(v)
+		}
+	}
+	if v, ok := os.LookupEnv("CORS_ALLOW_CREDENTIALS"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, fmt.Errorf("cors: CORS_ALLOW_CREDENTIALS: %w", err)
+		}
+		c.AllowCredentials = b
+	}
+	if v, ok := os.LookupEnv("CORS_MAX_AGE"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, fmt.Errorf("cors: CORS_MAX_AGE: %w", err)
+		}
+		c.MaxAge = d
+	}
+	return c, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"html/template"
-	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
+	"github.com/midsane/go-playground/08-http-server/server"
 )
 
 /*
@@ -44,5 +46,7 @@ func main() {
 	// 	)
 	// })
 
-	log.Fatal(router.Run(":8080"))
+	//gin's router is just an http.Handler, so it gets the same graceful
+	//shutdown as the net/http servers instead of router.Run
+	os.Exit(server.Run(context.Background(), &http.Server{Addr: ":8080", Handler: router}, server.Options{}))
 }
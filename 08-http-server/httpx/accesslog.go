@@ -0,0 +1,208 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/midsane/go-playground/06-context/ctxkey"
+)
+
+// LogFormat picks how AccessLog writes a request.
+type LogFormat int
+
+const (
+	// LogStructured is one slog record per request.
+	LogStructured LogFormat = iota
+	// LogCombined is the Apache/nginx Combined Log Format, one line per
+	// request, for tools that already parse it (goaccess, awstats, ...).
+	LogCombined
+)
+
+// AccessLogOptions configures AccessLog. The zero value logs structured
+// records to slog.Default().
+type AccessLogOptions struct {
+	Format LogFormat
+	// Logger receives LogStructured records, slog.Default() if nil.
+	Logger *slog.Logger
+	// Out receives LogCombined lines, os.Stdout if nil.
+	Out io.Writer
+	// Skip leaves matching requests out, e.g. readiness probes.
+	Skip func(*http.Request) bool
+}
+
+// logUser carries the user ID from auth middleware, which runs inside
+// AccessLog and only passes its principal further in, back out to the log.
+type logUser struct {
+	mu sync.Mutex
+	id string
+}
+
+var logUserKey = ctxkey.New[*logUser]("access log user")
+
+// SetLogUser records the authenticated user for the access log entry of
+// the request ctx belongs to. Without AccessLog in front it does nothing.
+func SetLogUser(ctx context.Context, userID string) {
+	if u, ok := logUserKey.From(ctx); ok {
+		u.mu.Lock()
+		u.id = userID
+		u.mu.Unlock()
+	}
+}
+
+// AccessLog logs every request once the handler returns: status, body
+// size, latency, time to first byte, request ID, user, user agent and
+// referer. Put it outside the recovery middleware so panics are logged
+// with the 500 they turned into.
+func AccessLog(opts AccessLogOptions) Middleware {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var mu sync.Mutex // one Write per line, but not every io.Writer is safe for concurrent use
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.Skip != nil && opts.Skip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			user := &logUser{}
+			rec := NewResponseRecorder(w)
+			start := rec.start
+			next.ServeHTTP(rec, r.WithContext(logUserKey.With(r.Context(), user)))
+			elapsed := time.Since(start)
+
+			user.mu.Lock()
+			userID := user.id
+			user.mu.Unlock()
+
+			if opts.Format == LogCombined {
+				line := combinedLine(r, rec, userID, start)
+				mu.Lock()
+				io.WriteString(out, line)
+				mu.Unlock()
+				return
+			}
+
+			level := slog.LevelInfo
+			if rec.Status() >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("proto", r.Proto),
+				slog.Int("status", rec.Status()),
+				slog.Int64("bytes", rec.BytesWritten()),
+				slog.Duration("duration", elapsed),
+				slog.Duration("ttfb", rec.TTFB()),
+				slog.String("remote_ip", remoteIP(r)),
+				slog.String("request_id", requestID(r)),
+				slog.String("user_id", userID),
+				slog.String("user_agent", r.UserAgent()),
+				slog.String("referer", r.Referer()),
+			)
+		})
+	}
+}
+
+// combinedLine formats
+//
+//	host ident user [time] "request" status bytes "referer" "user-agent"
+//
+// with "-" for anything unknown, as Apache does.
+func combinedLine(r *http.Request, rec *ResponseRecorder, user string, start time.Time) string {
+	bytes := "-"
+	if n := rec.BytesWritten(); n > 0 {
+		bytes = strconv.FormatInt(n, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %s %s %s\n",
+		orDash(remoteIP(r)),
+		orDash(strings.Map(noSpace, user)),
+		start.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto),
+		rec.Status(),
+		bytes,
+		strconv.Quote(orDash(r.Referer())),
+		strconv.Quote(orDash(r.UserAgent())),
+	)
+}
+
+// the user field isn't quoted, a space in it would shift every field after
+func noSpace(c rune) rune {
+	if c <= ' ' || c == '"' {
+		return '_'
+	}
+	return c
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func requestID(r *http.Request) string {
+	if id := ctxkey.RequestID(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID gives every request an ID, in ctxkey.RequestID and the
+// response's X-Request-ID header. A caller's X-Request-ID is kept if it
+// looks sane, so one ID can follow a request across services.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctxkey.WithRequestID(r.Context(), id)))
+	})
+}
+
+// at most 128 printable ASCII characters without spaces or quotes, so it
+// can't break a log line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return !strings.ContainsFunc(id, func(c rune) bool {
+		return c <= ' ' || c > '~' || c == '"'
+	})
+}
+
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
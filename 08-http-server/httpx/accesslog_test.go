@@ -0,0 +1,130 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/midsane/go-playground/06-context/ctxkey"
+)
+
+func TestAccessLogStructured(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	h := RequestID(AccessLog(AccessLogOptions{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetLogUser(r.Context(), "u-42")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})))
+
+	r := httptest.NewRequest(http.MethodGet, "/pot?x=1", nil)
+	r.Header.Set(RequestIDHeader, "req-1")
+	r.Header.Set("User-Agent", "curl/8.0")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	for k, want := range map[string]any{
+		"level":      "INFO",
+		"method":     "GET",
+		"path":       "/pot",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(15),
+		"remote_ip":  "192.0.2.1",
+		"request_id": "req-1",
+		"user_id":    "u-42",
+		"user_agent": "curl/8.0",
+	} {
+		if entry[k] != want {
+			t.Errorf("%s = %v, want %v", k, entry[k], want)
+		}
+	}
+}
+
+func TestAccessLogLevelAndSkip(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	h := AccessLog(AccessLogOptions{
+		Logger: logger,
+		Skip:   func(r *http.Request) bool { return r.URL.Path == "/readyz" },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if buf.Len() != 0 {
+		t.Errorf("skipped request was logged: %s", buf.String())
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	if !strings.Contains(buf.String(), "level=ERROR") || !strings.Contains(buf.String(), "status=502") {
+		t.Errorf("5xx logged as %s", buf.String())
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	var out bytes.Buffer
+	h := AccessLog(AccessLogOptions{Format: LogCombined, Out: &out})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetLogUser(r.Context(), "jane doe")
+		w.Write([]byte("hello"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/a?b=c", nil)
+	r.Header.Set("Referer", "https://example.com/")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	re := regexp.MustCompile(`^192\.0\.2\.1 - jane_doe \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /a\?b=c HTTP/1\.1" 200 5 "https://example\.com/" "-"\n$`)
+	if !re.MatchString(out.String()) {
+		t.Errorf("combined line %q", out.String())
+	}
+
+	// nothing written and no user: dashes
+	out.Reset()
+	AccessLog(AccessLogOptions{Format: LogCombined, Out: &out})(http.NotFoundHandler()).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/", nil))
+	if !strings.HasPrefix(out.String(), "192.0.2.1 - - [") || !strings.Contains(out.String(), `" 404 19 "-" "-"`) {
+		t.Errorf("combined line %q", out.String())
+	}
+}
+
+func TestSetLogUserWithoutAccessLog(t *testing.T) {
+	// must not panic
+	SetLogUser(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "u-1")
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = ctxkey.RequestID(r.Context())
+	}))
+
+	for in, keep := range map[string]bool{
+		"abc-123":                true,
+		"":                       false,
+		"has space":              false,
+		`quo"te`:                 false,
+		strings.Repeat("x", 129): false,
+		"ünïcode":                false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if in != "" {
+			r.Header.Set(RequestIDHeader, in)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := w.Header().Get(RequestIDHeader); got != seen || (got == in) != keep {
+			t.Errorf("%q: header %q, context %q", in, got, seen)
+		}
+		if !keep && len(seen) != 32 {
+			t.Errorf("%q: generated ID %q", in, seen)
+		}
+	}
+}
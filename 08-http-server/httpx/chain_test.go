@@ -0,0 +1,83 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// tag appends name to the X-Trace header on the way in.
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// trace runs h and returns the middleware that saw the request, in order.
+func trace(h http.Handler) string {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	return strings.Join(r.Header.Values("X-Trace"), ",")
+}
+
+var final = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+func TestChainOrder(t *testing.T) {
+	c := NewChain().Use("a", tag("a")).Use("b", tag("b")).Use("c", tag("c"))
+	if got := trace(c.Then(final)); got != "a,b,c" {
+		t.Errorf("Then ran %s, want a,b,c", got)
+	}
+	if got := trace(c.ThenFunc(final)); got != "a,b,c" {
+		t.Errorf("ThenFunc ran %s", got)
+	}
+	if got := c.Names(); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("Names = %v", got)
+	}
+	if got := trace(NewChain().Then(final)); got != "" {
+		t.Errorf("empty chain ran %s", got)
+	}
+}
+
+func TestChainBranchesAreIndependent(t *testing.T) {
+	// if the branches shared a backing array, admin's Use would overwrite
+	// the auth middleware api appended
+	base := NewChain().Use("log", tag("log")).Use("recover", tag("recover"))
+	api := base.Use("auth", tag("auth"))
+	admin := base.Use("admin", tag("admin"))
+
+	for name, tc := range map[string]struct {
+		c    Chain
+		want string
+	}{
+		"base":  {base, "log,recover"},
+		"api":   {api, "log,recover,auth"},
+		"admin": {admin, "log,recover,admin"},
+	} {
+		if got := trace(tc.c.Then(final)); got != tc.want {
+			t.Errorf("%s ran %s, want %s", name, got, tc.want)
+		}
+	}
+}
+
+func TestChainWithoutAndExtend(t *testing.T) {
+	base := NewChain().Use("log", tag("log")).Use("recover", tag("recover"))
+	if got := trace(base.Without("log").Then(final)); got != "recover" {
+		t.Errorf("Without ran %s", got)
+	}
+	if got := trace(base.Without("missing").Then(final)); got != "log,recover" {
+		t.Errorf("Without an unknown name ran %s", got)
+	}
+	if got := base.Names(); len(got) != 2 {
+		t.Errorf("Without changed the original: %v", got)
+	}
+
+	auth := NewChain().Use("auth", tag("auth"))
+	if got := trace(base.Extend(auth).Then(final)); got != "log,recover,auth" {
+		t.Errorf("Extend ran %s", got)
+	}
+}
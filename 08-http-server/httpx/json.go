@@ -0,0 +1,157 @@
+// Package httpx holds the HTTP plumbing every server here needs: JSON
+// request decoding and response encoding, content-type checks and a
+// middleware chain. Errors are apperr-compatible, so a server's writeError
+// turns them into the right problem+json response.
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+// WriteJSON writes v as a JSON response with the given status.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// DefaultMaxBytes is the body limit of the zero Decoder.
+const DefaultMaxBytes = 1 << 20
+
+// Decoder reads JSON request bodies. The zero value is strict: 1MB limit,
+// unknown fields rejected.
+type Decoder struct {
+	// MaxBytes limits the body; DefaultMaxBytes if zero.
+	MaxBytes int64
+	// AllowUnknownFields accepts fields that dst doesn't have.
+	AllowUnknownFields bool
+}
+
+// DecodeJSON decodes r's body into dst with the zero Decoder.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return Decoder{}.Decode(w, r, dst)
+}
+
+// Decode checks that r has a JSON content type and decodes exactly one
+// JSON value from its body into dst. Every failure is a *DecodeError. w is
+// needed so an oversized body closes the connection, see
+// http.MaxBytesReader.
+func (d Decoder) Decode(w http.ResponseWriter, r *http.Request, dst any) error {
+	if err := RequireJSON(r); err != nil {
+		return err
+	}
+	limit := d.MaxBytes
+	if limit <= 0 {
+		limit = DefaultMaxBytes
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	dec := json.NewDecoder(r.Body)
+	if !d.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	// a second value, or garbage after the first, means the client sent
+	// something other than what we're about to act on
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err)
+		}
+		return &DecodeError{kind: apperr.Invalid, Msg: "request body must contain a single JSON value", Err: err}
+	}
+	return nil
+}
+
+// DecodeError is why a request body was rejected. Its Kind maps to 400,
+// 413 or 415, and Msg is safe to show to the client.
+type DecodeError struct {
+	kind apperr.Kind
+	Msg  string
+	Err  error
+}
+
+func (e *DecodeError) Kind() apperr.Kind { return e.kind }
+
+// Status is the HTTP status for the error.
+func (e *DecodeError) Status() int { return e.kind.Status() }
+
+func (e *DecodeError) Public() string { return e.Msg }
+
+func (e *DecodeError) Error() string {
+	if e.Err == nil {
+		return "httpx: " + e.Msg
+	}
+	return "httpx: " + e.Msg + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+func decodeError(err error) *DecodeError {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		maxErr    *http.MaxBytesError
+	)
+	invalid := func(msg string) *DecodeError {
+		return &DecodeError{kind: apperr.Invalid, Msg: msg, Err: err}
+	}
+	switch {
+	case errors.As(err, &maxErr):
+		return &DecodeError{
+			kind: apperr.TooLarge,
+			Msg:  fmt.Sprintf("request body must not be larger than %d bytes", maxErr.Limit),
+			Err:  err,
+		}
+	case errors.Is(err, io.EOF):
+		return invalid("request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalid("request body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		return invalid(fmt.Sprintf("request body contains malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return invalid(fmt.Sprintf("field %q must be a JSON %s", typeErr.Field, jsonType(typeErr.Type)))
+		}
+		return invalid(fmt.Sprintf("request body must be a JSON %s", jsonType(typeErr.Type)))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this one
+		return invalid("request body contains unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return invalid("request body contains invalid JSON")
+}
+
+// jsonType names t the way a client thinks of it; Go type names mean
+// nothing to them.
+func jsonType(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	}
+	return "value"
+}
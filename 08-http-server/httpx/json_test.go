@@ -0,0 +1,110 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func jsonRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func TestDecode(t *testing.T) {
+	var u user
+	err := DecodeJSON(httptest.NewRecorder(), jsonRequest("application/json", `{"name":"jane","age":30}`), &u)
+	if err != nil || u != (user{"jane", 30}) {
+		t.Fatalf("DecodeJSON = %+v, %v", u, err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		dec  Decoder
+		body string
+		kind apperr.Kind
+		msg  string
+	}{
+		{"empty", Decoder{}, ``, apperr.Invalid, "request body must not be empty"},
+		{"truncated", Decoder{}, `{"name":`, apperr.Invalid, "request body contains malformed JSON"},
+		{"syntax", Decoder{}, `{"name" "jane"}`, apperr.Invalid, "request body contains malformed JSON at offset 9"},
+		{"field type", Decoder{}, `{"age":"old"}`, apperr.Invalid, `field "age" must be a JSON number`},
+		{"body type", Decoder{}, `[1,2]`, apperr.Invalid, "request body must be a JSON object"},
+		{"unknown field", Decoder{}, `{"name":"jane","admin":true}`, apperr.Invalid, `request body contains unknown field "admin"`},
+		{"two values", Decoder{}, `{"name":"a"}{"name":"b"}`, apperr.Invalid, "request body must contain a single JSON value"},
+		{"too large", Decoder{MaxBytes: 10}, `{"name":"jane"}`, apperr.TooLarge, "request body must not be larger than 10 bytes"},
+		// the limit also covers what comes after the first value
+		{"too large after", Decoder{MaxBytes: 16}, `{"name":"jane"} 1234567890`, apperr.TooLarge, "request body must not be larger than 16 bytes"},
+	} {
+		var u user
+		err := tc.dec.Decode(httptest.NewRecorder(), jsonRequest("application/json", tc.body), &u)
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			t.Errorf("%s: %v is not a *DecodeError", tc.name, err)
+			continue
+		}
+		if de.Kind() != tc.kind || de.Msg != tc.msg || de.Public() != tc.msg {
+			t.Errorf("%s: %v %q, want %v %q", tc.name, de.Kind(), de.Msg, tc.kind, tc.msg)
+		}
+	}
+}
+
+func TestDecodeAllowUnknownFields(t *testing.T) {
+	var u user
+	dec := Decoder{AllowUnknownFields: true}
+	if err := dec.Decode(httptest.NewRecorder(), jsonRequest("application/json", `{"name":"jane","admin":true}`), &u); err != nil || u.Name != "jane" {
+		t.Errorf("Decode = %+v, %v", u, err)
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	for ct, ok := range map[string]bool{
+		"application/json":                  true,
+		"Application/JSON; charset=UTF-8":   true,
+		"application/problem+json":          true,
+		"application/merge-patch+json":      true,
+		"":                                  false,
+		"text/plain":                        false,
+		"text/json":                         false,
+		"application/jsonp":                 false,
+		"application/json; charset=latin1":  false,
+		"application/json; charset":         false,
+		"multipart/form-data; boundary=xyz": false,
+		"application/x-www-form-urlencoded": false,
+	} {
+		err := RequireJSON(jsonRequest(ct, ""))
+		if ok != (err == nil) {
+			t.Errorf("%q: RequireJSON = %v", ct, err)
+			continue
+		}
+		var de *DecodeError
+		if err != nil && (!errors.As(err, &de) || de.Status() != http.StatusUnsupportedMediaType) {
+			t.Errorf("%q: %v, want a 415 *DecodeError", ct, err)
+		}
+	}
+
+	mt, params, err := MediaType(jsonRequest("Application/JSON; Charset=utf-8", ""))
+	if err != nil || mt != "application/json" || params["charset"] != "utf-8" {
+		t.Errorf("MediaType = %q, %v, %v", mt, params, err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJSON(w, http.StatusCreated, user{"jane", 30})
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/json" || w.Body.String() != `{"name":"jane","age":30}`+"\n" {
+		t.Errorf("WriteJSON = %d %q %q", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+}
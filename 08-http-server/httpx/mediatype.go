@@ -0,0 +1,37 @@
+package httpx
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+// MediaType parses r's Content-Type, e.g. "application/json; charset=utf-8"
+// gives "application/json" and {"charset": "utf-8"}. The type is lower
+// case. A missing header is "" with no error.
+func MediaType(r *http.Request) (string, map[string]string, error) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return "", nil, nil
+	}
+	return mime.ParseMediaType(ct)
+}
+
+// RequireJSON accepts application/json and structured "+json" types such as
+// application/problem+json, with an optional UTF-8 charset. Anything else
+// is a 415 *DecodeError.
+func RequireJSON(r *http.Request) error {
+	mt, params, err := MediaType(r)
+	if err != nil {
+		return &DecodeError{kind: apperr.UnsupportedMediaType, Msg: "malformed Content-Type header", Err: err}
+	}
+	if mt != "application/json" && !(strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json")) {
+		return &DecodeError{kind: apperr.UnsupportedMediaType, Msg: "Content-Type must be application/json"}
+	}
+	if cs, ok := params["charset"]; ok && !strings.EqualFold(cs, "utf-8") {
+		return &DecodeError{kind: apperr.UnsupportedMediaType, Msg: "JSON bodies must be UTF-8"}
+	}
+	return nil
+}
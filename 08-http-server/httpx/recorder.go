@@ -0,0 +1,130 @@
+package httpx
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseRecorder wraps a ResponseWriter and remembers the status, the
+// body size and when the first byte went out, for access logs and
+// metrics. It implements http.Flusher and http.Hijacker, and Unwrap lets
+// http.ResponseController reach the rest of the underlying writer, so
+// wrapping doesn't take anything away from the handler.
+type ResponseRecorder struct {
+	http.ResponseWriter
+
+	start       time.Time
+	status      int
+	bytes       int64
+	ttfb        time.Duration
+	wroteHeader bool
+	hijacked    bool
+}
+
+// NewResponseRecorder wraps w, timing from now.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, start: time.Now()}
+}
+
+func (rw *ResponseRecorder) WriteHeader(code int) {
+	if rw.wroteHeader || rw.hijacked {
+		rw.ResponseWriter.WriteHeader(code) // let net/http complain
+		return
+	}
+	rw.ResponseWriter.WriteHeader(code)
+	// 1xx (103 Early Hints) can be sent any number of times before the
+	// real status; 101 is final, the connection changes protocol after it
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		return
+	}
+	rw.markWritten(code)
+}
+
+func (rw *ResponseRecorder) markWritten(code int) {
+	rw.wroteHeader = true
+	rw.status = code
+	rw.ttfb = time.Since(rw.start)
+}
+
+func (rw *ResponseRecorder) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the sendfile path of the underlying writer, used by
+// http.ServeContent and io.Copy.
+func (rw *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := rw.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(rw.ResponseWriter, src)
+	}
+	rw.bytes += n
+	return n, err
+}
+
+// Flush sends what's buffered, headers included, if the underlying writer
+// can.
+func (rw *ResponseRecorder) Flush() {
+	rw.FlushError()
+}
+
+// FlushError is Flush for http.ResponseController, which prefers it.
+func (rw *ResponseRecorder) FlushError() error {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Hijack hands the connection over, e.g. for a websocket upgrade. Nothing
+// written afterwards is counted.
+func (rw *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(rw.ResponseWriter).Hijack()
+	if err == nil {
+		rw.hijacked = true
+		if !rw.wroteHeader {
+			rw.markWritten(http.StatusSwitchingProtocols)
+		}
+	}
+	return conn, buf, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (rw *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Status is the status sent, 200 if the handler wrote nothing (net/http
+// sends that when it returns), 101 after a hijack.
+func (rw *ResponseRecorder) Status() int {
+	if !rw.wroteHeader {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Written reports whether the status line has gone out.
+func (rw *ResponseRecorder) Written() bool { return rw.wroteHeader }
+
+// Hijacked reports whether the handler took the connection over.
+func (rw *ResponseRecorder) Hijacked() bool { return rw.hijacked }
+
+// BytesWritten is the size of the body so far, headers not included.
+func (rw *ResponseRecorder) BytesWritten() int64 { return rw.bytes }
+
+// TTFB is the time from NewResponseRecorder until the status was written,
+// 0 if it hasn't been yet.
+func (rw *ResponseRecorder) TTFB() time.Duration { return rw.ttfb }
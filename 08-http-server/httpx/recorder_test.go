@@ -0,0 +1,100 @@
+package httpx
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecorderDefaults(t *testing.T) {
+	rec := NewResponseRecorder(httptest.NewRecorder())
+	if rec.Status() != http.StatusOK || rec.Written() || rec.BytesWritten() != 0 || rec.TTFB() != 0 {
+		t.Errorf("before writing: %d %v %d %v", rec.Status(), rec.Written(), rec.BytesWritten(), rec.TTFB())
+	}
+
+	rec.Write([]byte("hello"))
+	rec.Write([]byte(", world"))
+	if rec.Status() != http.StatusOK || !rec.Written() || rec.BytesWritten() != 12 {
+		t.Errorf("after Write: %d %v %d", rec.Status(), rec.Written(), rec.BytesWritten())
+	}
+}
+
+func TestRecorderStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w)
+	rec.WriteHeader(http.StatusNotFound)
+	rec.WriteHeader(http.StatusInternalServerError) // superfluous, not recorded
+	n, _ := rec.ReadFrom(strings.NewReader("not found"))
+	if rec.Status() != http.StatusNotFound || n != 9 || rec.BytesWritten() != 9 {
+		t.Errorf("got %d, %d bytes", rec.Status(), rec.BytesWritten())
+	}
+	if w.Code != http.StatusNotFound || w.Body.String() != "not found" {
+		t.Errorf("underlying writer got %d %q", w.Code, w.Body)
+	}
+}
+
+func TestRecorderInformational(t *testing.T) {
+	rec := NewResponseRecorder(httptest.NewRecorder())
+	rec.WriteHeader(http.StatusEarlyHints)
+	rec.WriteHeader(http.StatusEarlyHints)
+	if rec.Written() {
+		t.Fatal("103 Early Hints counted as the final status")
+	}
+	rec.WriteHeader(http.StatusAccepted)
+	if rec.Status() != http.StatusAccepted {
+		t.Errorf("Status = %d after 103, 103, 202", rec.Status())
+	}
+
+	rec = NewResponseRecorder(httptest.NewRecorder())
+	rec.WriteHeader(http.StatusSwitchingProtocols)
+	if !rec.Written() || rec.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("101 isn't final: %v %d", rec.Written(), rec.Status())
+	}
+}
+
+func TestRecorderFlushAndUnwrap(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewResponseRecorder(w)
+	if err := http.NewResponseController(rec).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Flushed || !rec.Written() || rec.Status() != http.StatusOK {
+		t.Errorf("Flush: flushed %v, written %v, status %d", w.Flushed, rec.Written(), rec.Status())
+	}
+	if rec.Unwrap() != w {
+		t.Error("Unwrap didn't return the wrapped writer")
+	}
+}
+
+// hijacker is a ResponseWriter that can give its connection away.
+type hijacker struct {
+	*httptest.ResponseRecorder
+	conn net.Conn
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.conn, nil, nil
+}
+
+func TestRecorderHijack(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	rec := NewResponseRecorder(hijacker{httptest.NewRecorder(), server})
+	conn, _, err := http.NewResponseController(rec).Hijack()
+	if err != nil || conn != server {
+		t.Fatalf("Hijack = %v, %v", conn, err)
+	}
+	conn.Close()
+	if !rec.Hijacked() || rec.Status() != http.StatusSwitchingProtocols {
+		t.Errorf("after Hijack: hijacked %v, status %d", rec.Hijacked(), rec.Status())
+	}
+
+	// a writer that can't hijack leaves the recorder as it was
+	rec = NewResponseRecorder(httptest.NewRecorder())
+	if _, _, err := rec.Hijack(); err == nil || rec.Hijacked() || rec.Written() {
+		t.Errorf("Hijack without support = %v, hijacked %v", err, rec.Hijacked())
+	}
+}
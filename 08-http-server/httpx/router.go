@@ -0,0 +1,138 @@
+package httpx
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+// Router is a thin layer over http.ServeMux (Go 1.22+ patterns, so
+// "GET /users/{id}" and r.PathValue work) that adds route groups with
+// their own middleware, and answers unmatched requests with problem+json:
+// 405 with an Allow header when the path exists under other methods, 404
+// otherwise.
+//
+//	rt := httpx.NewRouter()
+//	rt.HandleFunc("POST /login", login)
+//	api := rt.Group("/api")
+//	api.Use("auth", JWTMiddleware)
+//	api.HandleFunc("GET /users/{id}", getUser)
+type Router struct {
+	routes *routes
+	prefix string
+	chain  Chain
+}
+
+// routes is shared by a router and its groups.
+type routes struct {
+	mux     *http.ServeMux
+	mu      sync.RWMutex
+	methods []string // every method some route was registered with
+}
+
+// NewRouter returns an empty router.
+func NewRouter() *Router {
+	return &Router{routes: &routes{mux: http.NewServeMux()}}
+}
+
+// Group returns a router that registers routes under prefix (e.g.
+// "/api") on the same mux, starting with this router's middleware.
+func (rt *Router) Group(prefix string) *Router {
+	return &Router{
+		routes: rt.routes,
+		prefix: rt.prefix + strings.TrimSuffix(prefix, "/"),
+		chain:  rt.chain,
+	}
+}
+
+// Use adds middleware for the routes registered on rt from now on. Groups
+// made afterwards inherit it; the parent of a group is not affected.
+func (rt *Router) Use(name string, mw Middleware) {
+	rt.chain = rt.chain.Use(name, mw)
+}
+
+// Handle registers h for pattern, "[METHOD ]/path", with the group prefix
+// put in front of the path and the group middleware around h. A pattern
+// without a method matches every method.
+func (rt *Router) Handle(pattern string, h http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	path = strings.TrimLeft(path, " ")
+	if rt.prefix != "" && path == "/" {
+		// "/api" + "/" would match everything under /api
+		path = "/{$}"
+	}
+	full := rt.prefix + path
+	if method != "" {
+		full = method + " " + full
+		rt.routes.addMethod(method)
+	}
+	rt.routes.mux.Handle(full, rt.chain.Then(h))
+}
+
+// HandleFunc is Handle for a handler function.
+func (rt *Router) HandleFunc(pattern string, fn http.HandlerFunc) {
+	rt.Handle(pattern, fn)
+}
+
+func (rs *routes) addMethod(m string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if !slices.Contains(rs.methods, m) {
+		rs.methods = append(rs.methods, m)
+		slices.Sort(rs.methods)
+	}
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.routes.mux.Handler(r); pattern != "" {
+		// ServeMux.Handler doesn't fill in path values, ServeHTTP does
+		rt.routes.mux.ServeHTTP(w, r)
+		return
+	}
+	if allow := rt.routes.allowed(r); len(allow) > 0 {
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		apperr.NewProblem(r, http.StatusMethodNotAllowed, r.Method+" is not allowed here").Write(w)
+		return
+	}
+	apperr.NewProblem(r, http.StatusNotFound, "no route for "+r.URL.Path).Write(w)
+}
+
+// allowed lists the methods r's path would have matched with.
+func (rs *routes) allowed(r *http.Request) []string {
+	rs.mu.RLock()
+	methods := rs.methods
+	rs.mu.RUnlock()
+
+	var allow []string
+	probe := *r
+	for _, m := range methods {
+		probe.Method = m
+		if _, pattern := rs.mux.Handler(&probe); pattern != "" {
+			allow = append(allow, m)
+			// ServeMux serves HEAD with GET handlers
+			if m == http.MethodGet && !slices.Contains(methods, http.MethodHead) {
+				allow = append(allow, http.MethodHead)
+			}
+		}
+	}
+	slices.Sort(allow)
+	return allow
+}
+
+// PathInt parses the path parameter name as an int, e.g. {id} in
+// "GET /users/{id}". A bad value is an apperr.Invalid error naming it.
+func PathInt(r *http.Request, name string) (int, error) {
+	v := r.PathValue(name)
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, apperr.Wrap(err, apperr.Invalid, name+" must be an integer")
+	}
+	return n, nil
+}
@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"time"
+
+	"github.com/midsane/go-playground/06-context/deadline"
 )
 
 func main() {
-	resp, err := http.Get("http://localhost:8080")
+	//never call a server without a budget -> the ctx deadline bounds the whole call,
+	//and deadline.Transport sends what's left as X-Request-Timeout so the server
+	//stops working on it at the same moment we stop waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:8080", nil)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	resp, err := deadline.NewClient(10 * time.Second).Do(req)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
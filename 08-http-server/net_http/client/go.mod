@@ -1,3 +1,7 @@
 module github.com/midsane/go-playground/08-http-server/client
 
 go 1.25.6
+
+require github.com/midsane/go-playground v0.0.0
+
+replace github.com/midsane/go-playground => ../../..
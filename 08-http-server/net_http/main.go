@@ -1,22 +1,42 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"os"
+	"runtime/debug"
 	"strings"
 	"time"
+
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/midsane/go-playground/04-logging/audit"
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+	"github.com/midsane/go-playground/06-context/ctxkey"
+	"github.com/midsane/go-playground/06-context/deadline"
+	"github.com/midsane/go-playground/07-concurrency/leakcheck"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+	"github.com/midsane/go-playground/08-http-server/cors"
+	"github.com/midsane/go-playground/08-http-server/httpx"
+	"github.com/midsane/go-playground/08-http-server/server"
+	"github.com/midsane/go-playground/20-observability/errreport"
 )
 
-type contextKey string
+var jwtSecret = []byte("super-secret-key")
 
-const userContextKey contextKey = "user"
+// panics and 5xx errors are sent to the local collector (20-observability/cmd/collector)
+// when ERROR_COLLECTOR_URL is set. a nil reporter just drops them.
+var reporter *errreport.Client
 
-var jwtSecret = []byte("super-secret-key")
+// security relevant actions go to a separate hash-chained audit trail,
+// check it with 04-logging/cmd/auditverify. AUDIT_LOG moves it; every binary
+// has its own default so two of them started in one directory don't write
+// into (and fork) the same chain.
+var auditLog *audit.Logger
+
+const defaultAuditLog = "net_http-audit.log"
 
 // =========================
 // Models
@@ -28,52 +48,40 @@ type User struct {
 	Email string `json:"email"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 // =========================
 // Utility Helpers
 // =========================
 
-func writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+// writeError renders err as an RFC 7807 problem. only the public message goes
+// to the client, 5xx causes are logged and reported here.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperr.KindOf(err) == apperr.Internal {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		reporter.CaptureError(r, err)
+	}
+	apperr.Write(w, r, err)
 }
 
-func parseJSON(r *http.Request, dst interface{}) error {
-	if r.Header.Get("Content-Type") != "application/json" {
-		return errors.New("content type must be application/json")
+func recordAudit(e audit.Event) {
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Record(e); err != nil {
+		log.Println("audit:", err)
 	}
-	return json.NewDecoder(r.Body).Decode(dst)
 }
 
 // =========================
 // Middleware
 // =========================
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s %v",
-			r.Method,
-			r.URL.Path,
-			r.RemoteAddr,
-			time.Since(start),
-		)
-	})
-}
-
 func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				log.Println("PANIC:", err)
-				writeJSON(w, http.StatusInternalServerError, ErrorResponse{
-					Error: "internal server error",
-				})
+				reporter.CapturePanic(r, err, debug.Stack())
+				apperr.Write(w, r, apperr.New(apperr.Internal, "internal server error"))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -82,12 +90,17 @@ func RecoveryMiddleware(next http.Handler) http.Handler {
 
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every rejection is an access denial for the audit trail too
+		deny := func(msg string) {
+			recordAudit(audit.Event{Action: "auth.token", Target: r.Method + " " + r.URL.Path, Outcome: audit.Denied,
+				Fields: map[string]any{"remote_addr": r.RemoteAddr, "reason": msg}})
+			writeError(w, r, apperr.New(apperr.Unauthorized, msg))
+		}
+
 		auth := r.Header.Get("Authorization")
 
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-				Error: "missing or invalid token",
-			})
+			deny("missing or invalid token")
 			return
 		}
 
@@ -98,23 +111,24 @@ func JWTMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-				Error: "invalid token",
-			})
+			deny("invalid token")
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-				Error: "invalid claims",
-			})
+			deny("invalid claims")
 			return
 		}
 
-		userID := claims["user_id"].(string)
+		userID, ok := claims["user_id"].(string)
+		if !ok || userID == "" {
+			deny("invalid claims")
+			return
+		}
 
-		ctx := context.WithValue(r.Context(), userContextKey, userID)
+		httpx.SetLogUser(r.Context(), userID)
+		ctx := ctxkey.WithPrincipal(r.Context(), ctxkey.Principal{UserID: userID})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -124,30 +138,19 @@ func JWTMiddleware(next http.Handler) http.Handler {
 // =========================
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
-			Error: "method not allowed",
-		})
-		return
-	}
-
 	type LoginRequest struct {
 		UserID string `json:"user_id"`
 	}
 
 	var req LoginRequest
 
-	if err := parseJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-		})
+	if err := httpx.DecodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	if req.UserID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "user_id required",
-		})
+		writeError(w, r, apperr.New(apperr.Invalid, "user_id required"))
 		return
 	}
 
@@ -158,107 +161,167 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	tokenStr, err := token.SignedString(jwtSecret)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{
-			Error: "failed to sign token",
-		})
+		recordAudit(audit.Event{Action: "auth.login", Actor: req.UserID, Outcome: audit.Failure,
+			Fields: map[string]any{"remote_addr": r.RemoteAddr, "reason": "sign token"}})
+		writeError(w, r, apperr.New(apperr.Internal, "failed to sign token"))
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
+	recordAudit(audit.Event{Action: "auth.login", Actor: req.UserID, Outcome: audit.Success,
+		Fields: map[string]any{"remote_addr": r.RemoteAddr}})
+	httpx.WriteJSON(w, http.StatusOK, map[string]string{
 		"token": tokenStr,
 	})
 }
 
 func ProfileHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(userContextKey).(string)
+	principal, ok := ctxkey.PrincipalFrom(r.Context())
+	if !ok {
+		writeError(w, r, apperr.New(apperr.Unauthorized, "not authenticated"))
+		return
+	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"user_id": userID,
+	httpx.WriteJSON(w, http.StatusOK, map[string]string{
+		"user_id": principal.UserID,
 		"message": "protected profile data",
 	})
 }
 
-func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
-			Error: "method not allowed",
-		})
-		return
-	}
+func CreateUserHandler(repo UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var user User
 
-	var user User
-
-	if err := parseJSON(r, &user); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-		})
-		return
-	}
+		if err := httpx.DecodeJSON(w, r, &user); err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-	if user.ID == "" || user.Email == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "id and email required",
-		})
-		return
-	}
+		user.Email = strings.TrimSpace(user.Email)
+		if user.ID == "" || user.Email == "" {
+			writeError(w, r, apperr.New(apperr.Invalid, "id and email required"))
+			return
+		}
 
-	writeJSON(w, http.StatusCreated, user)
-}
+		created, err := repo.Create(r.Context(), user)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-func GetUserHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "id query param required",
-		})
-		return
+		principal, _ := ctxkey.PrincipalFrom(r.Context())
+		recordAudit(audit.Event{Action: "user.create", Actor: principal.UserID, Target: created.ID,
+			Outcome: audit.Success, Fields: map[string]any{"remote_addr": r.RemoteAddr}})
+		httpx.WriteJSON(w, http.StatusCreated, created)
 	}
-
-	writeJSON(w, http.StatusOK, User{
-		ID:    id,
-		Name:  "Demo User",
-		Email: "demo@example.com",
-	})
 }
 
-// =========================
-// Middleware Chain Builder
-// =========================
+func GetUserHandler(repo UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := repo.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
 
-func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
-	for i := len(middlewares) - 1; i >= 0; i-- {
-		h = middlewares[i](h)
+		httpx.WriteJSON(w, http.StatusOK, user)
 	}
-	return h
 }
 
 // =========================
 // Main
 // =========================
 
+// config is what main takes from the environment.
+type config struct {
+	AuditLog string
+	CORS     cors.Config
+}
+
+func loadConfig() (config, error) {
+	cfg := config{
+		AuditLog: cmp.Or(os.Getenv("AUDIT_LOG"), defaultAuditLog),
+		// the browser front-end runs on another origin. tokens go in the
+		// Authorization header, not cookies, so no credentials.
+		CORS: cors.Config{
+			AllowedOrigins: []string{"http://localhost:3000"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders: []string{"Authorization", "Content-Type", httpx.RequestIDHeader, deadline.Header},
+			ExposedHeaders: []string{httpx.RequestIDHeader},
+			MaxAge:         10 * time.Minute,
+		},
+	}
+	var err error
+	cfg.CORS, err = cors.FromEnv(cfg.CORS)
+	return cfg, err
+}
+
 func main() {
 	logger := log.New(os.Stdout, "", log.LstdFlags)
+	ready := &server.Readiness{}
+	// cleanup runs as shutdown hooks rather than defers: os.Exit below skips defers
+	var hooks []server.Hook
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	al, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auditLog = al
+	hooks = append(hooks, server.Hook{Name: "audit log", Fn: func(context.Context) error { return al.Close() }})
+
+	if url := os.Getenv("ERROR_COLLECTOR_URL"); url != "" {
+		reporter = errreport.New(errreport.Options{
+			Endpoint:    url,
+			Release:     os.Getenv("RELEASE"),
+			Environment: os.Getenv("ENVIRONMENT"),
+		})
+		safego.SetHook(reporter.Hook())
+		hooks = append(hooks, server.Hook{Name: "error reporter", Fn: reporter.Close})
+	}
 
-	mux := http.NewServeMux()
+	mux := httpx.NewRouter()
 
-	mux.Handle("/login", http.HandlerFunc(LoginHandler))
-	mux.Handle("/users", http.HandlerFunc(CreateUserHandler))
-	mux.Handle("/user", http.HandlerFunc(GetUserHandler))
+	mux.Handle("GET /readyz", ready)
+	mux.HandleFunc("POST /login", LoginHandler)
 
-	protectedProfile := Chain(
-		http.HandlerFunc(ProfileHandler),
-		JWTMiddleware,
-	)
+	users := newMemoryUsers()
 
-	mux.Handle("/profile", protectedProfile)
+	// everything registered on this group needs a token
+	protected := mux.Group("/")
+	protected.Use("jwt", JWTMiddleware)
+	protected.HandleFunc("GET /profile", ProfileHandler)
+	protected.HandleFunc("POST /users", CreateUserHandler(users))
+	protected.HandleFunc("GET /users/{id}", GetUserHandler(users))
 
-	finalHandler := Chain(
-		mux,
-		RecoveryMiddleware,
-		LoggingMiddleware,
-	)
+	// the access log sits outside recover so a panic shows up as the 500 it
+	// became. LOG_FORMAT=combined switches it to Apache-style lines.
+	accessLog := httpx.AccessLogOptions{Skip: func(r *http.Request) bool { return r.URL.Path == "/readyz" }}
+	if os.Getenv("LOG_FORMAT") == "combined" {
+		accessLog.Format = httpx.LogCombined
+	}
 
-	server := &http.Server{
+	corsMW, err := cors.New(cfg.CORS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	finalHandler := httpx.NewChain().
+		Use("request-id", httpx.RequestID).
+		Use("log", httpx.AccessLog(accessLog)).
+		Use("cors", corsMW.Handler).
+		Use("recover", RecoveryMiddleware).
+		// the caller's X-Request-Timeout becomes the request ctx deadline, never
+		// more than the write timeout since the response couldn't be sent anyway.
+		// outbound calls made with r.Context() and deadline.NewClient pass the
+		// remaining budget on.
+		Use("deadline", deadline.Middleware(deadline.Policy{Default: 5 * time.Second, Max: 5 * time.Second})).
+		Then(mux)
+
+	srv := &http.Server{
 		Addr:         ":8080",
 		Handler:      finalHandler,
 		ReadTimeout:  5 * time.Second,
@@ -266,9 +329,25 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	logger.Println("Server running on :8080")
-
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
-	}
+	// admin listener, localhost only -> live goroutines grouped by stack, a group
+	// whose count keeps climbing is a leak
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/debug/goroutines", leakcheck.Handler())
+	admin := &http.Server{Addr: "localhost:6060", Handler: adminMux}
+	safego.Go(context.Background(), func(context.Context) error {
+		if err := admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	hooks = append(hooks, server.Hook{Name: "admin server", Fn: admin.Shutdown})
+
+	// requests get at most 5s (the write timeout) so a 10s drain is enough for
+	// all of them to finish
+	os.Exit(server.Run(context.Background(), srv, server.Options{
+		ShutdownTimeout: 10 * time.Second,
+		Ready:           ready,
+		Hooks:           hooks,
+		Logger:          logger,
+	}))
 }
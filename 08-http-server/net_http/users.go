@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+)
+
+var (
+	errUserNotFound = apperr.New(apperr.NotFound, "user not found")
+	errUserExists   = apperr.New(apperr.Conflict, "a user with this id already exists")
+	errEmailTaken   = apperr.New(apperr.Conflict, "email already registered")
+)
+
+// UserRepository stores users. Get returns errUserNotFound for an unknown
+// id, Create returns errUserExists or errEmailTaken on a clash.
+type UserRepository interface {
+	Create(ctx context.Context, u User) (User, error)
+	Get(ctx context.Context, id string) (User, error)
+}
+
+// memoryUsers is a UserRepository in a map, a stand-in until 11-database.
+type memoryUsers struct {
+	mu      sync.RWMutex
+	byID    map[string]User
+	byEmail map[string]string // normalized email -> id
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{
+		byID:    make(map[string]User),
+		byEmail: make(map[string]string),
+	}
+}
+
+// emails compare case-insensitively, Bob@x.com and bob@x.com are one account
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (m *memoryUsers) Create(_ context.Context, u User) (User, error) {
+	email := normalizeEmail(u.Email)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.byID[u.ID]; ok {
+		return User{}, errUserExists
+	}
+	if _, ok := m.byEmail[email]; ok {
+		return User{}, errEmailTaken
+	}
+	m.byID[u.ID] = u
+	m.byEmail[email] = u.ID
+	return u, nil
+}
+
+func (m *memoryUsers) Get(_ context.Context, id string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.byID[id]
+	if !ok {
+		return User{}, errUserNotFound
+	}
+	return u, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func usersMux() *http.ServeMux {
+	users := newMemoryUsers()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users", CreateUserHandler(users))
+	mux.HandleFunc("GET /users/{id}", GetUserHandler(users))
+	return mux
+}
+
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// problem checks w is an RFC 7807 problem with the given status and detail.
+func problem(t *testing.T, w *httptest.ResponseRecorder, status int, detail string) {
+	t.Helper()
+	var p struct {
+		Status int    `json:"status"`
+		Detail string `json:"detail"`
+	}
+	if w.Code != status || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Status != status || p.Detail != detail {
+		t.Errorf("problem %+v (%v), want %d %q", p, err, status, detail)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	mux := usersMux()
+	problem(t, do(mux, http.MethodGet, "/users/nobody", ""), http.StatusNotFound, "user not found")
+
+	if w := do(mux, http.MethodPost, "/users", `{"id":"u1","name":"Jane","email":"jane@example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", w.Code, w.Body)
+	}
+	w := do(mux, http.MethodGet, "/users/u1", "")
+	var u User
+	if err := json.Unmarshal(w.Body.Bytes(), &u); w.Code != http.StatusOK || err != nil || u != (User{"u1", "Jane", "jane@example.com"}) {
+		t.Errorf("get = %d %+v", w.Code, u)
+	}
+}
+
+func TestCreateUserUniqueness(t *testing.T) {
+	mux := usersMux()
+	if w := do(mux, http.MethodPost, "/users", `{"id":"u1","email":"Bob@Example.com"}`); w.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", w.Code, w.Body)
+	}
+
+	problem(t, do(mux, http.MethodPost, "/users", `{"id":"u1","email":"other@example.com"}`),
+		http.StatusConflict, "a user with this id already exists")
+	problem(t, do(mux, http.MethodPost, "/users", `{"id":"u2","email":" bob@example.COM "}`),
+		http.StatusConflict, "email already registered")
+
+	// the rejected users weren't stored
+	problem(t, do(mux, http.MethodGet, "/users/u2", ""), http.StatusNotFound, "user not found")
+	w := do(mux, http.MethodGet, "/users/u1", "")
+	if !strings.Contains(w.Body.String(), "Bob@Example.com") {
+		t.Errorf("u1 was overwritten: %s", w.Body)
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	mux := usersMux()
+	problem(t, do(mux, http.MethodPost, "/users", `{"id":"u1","email":"  "}`), http.StatusBadRequest, "id and email required")
+	problem(t, do(mux, http.MethodPost, "/users", `{"email":"a@example.com"}`), http.StatusBadRequest, "id and email required")
+	problem(t, do(mux, http.MethodPost, "/users", `{"id":"u1","email":"a@example.com","admin":true}`),
+		http.StatusBadRequest, `request body contains unknown field "admin"`)
+}
@@ -0,0 +1,204 @@
+// Package server runs an *http.Server until SIGINT/SIGTERM and then shuts
+// it down without cutting off requests in flight:
+//
+//  1. readiness flips to false, so load balancers stop routing here;
+//  2. after ReadinessDelay the listener closes and Shutdown waits for
+//     active requests, up to ShutdownTimeout (a second signal cuts the
+//     wait short);
+//  3. shutdown hooks run, last registered first, like defers.
+//
+// Run returns the process exit code, so a main ends with
+//
+//	os.Exit(server.Run(ctx, srv, opts))
+//
+// and anything main would have deferred goes in a hook instead, since
+// os.Exit skips defers.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Exit codes returned by Run.
+const (
+	// ExitOK: stopped on a signal or ctx, every request finished and every
+	// hook succeeded.
+	ExitOK = 0
+	// ExitServeError: the server couldn't listen or stopped by itself.
+	ExitServeError = 1
+	// ExitForced: requests were still running when the drain ended and
+	// their connections were closed.
+	ExitForced = 2
+	// ExitHookFailed: the server stopped cleanly but a shutdown hook
+	// returned an error.
+	ExitHookFailed = 3
+)
+
+// Hook is a cleanup step run after the server has stopped.
+type Hook struct {
+	Name string
+	Fn   func(ctx context.Context) error
+}
+
+// Options configures Run. The zero value is usable.
+type Options struct {
+	// ShutdownTimeout bounds the readiness delay plus waiting for in-flight
+	// requests; 30s if zero.
+	ShutdownTimeout time.Duration
+	// ReadinessDelay is how long to keep serving after readiness goes
+	// false, so load balancers have time to notice. Zero skips it.
+	ReadinessDelay time.Duration
+	// HookTimeout bounds all hooks together; 10s if zero.
+	HookTimeout time.Duration
+	// Ready, if set, is true while the server accepts traffic.
+	Ready *Readiness
+	// Hooks run in reverse order on the way out, whatever the exit code.
+	Hooks []Hook
+	// Logger defaults to log.Default().
+	Logger *log.Logger
+	// Signals defaults to SIGINT and SIGTERM.
+	Signals []os.Signal
+}
+
+func (o Options) withDefaults() Options {
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = 30 * time.Second
+	}
+	if o.HookTimeout <= 0 {
+		o.HookTimeout = 10 * time.Second
+	}
+	if o.Logger == nil {
+		o.Logger = log.Default()
+	}
+	if len(o.Signals) == 0 {
+		o.Signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	if o.Ready == nil {
+		o.Ready = &Readiness{}
+	}
+	return o
+}
+
+// Run serves srv until ctx ends, a signal arrives or the server fails, then
+// shuts down as described in the package doc and returns the exit code.
+func Run(ctx context.Context, srv *http.Server, opts Options) int {
+	opts = opts.withDefaults()
+	logger := opts.Logger
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, opts.Signals...)
+	defer signal.Stop(sigs)
+
+	// listen first so a bad address fails here, before we claim readiness
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Printf("server: %v", err)
+		return runHooks(opts, ExitServeError)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// certificates come from TLSConfig
+			serveErr <- srv.ServeTLS(ln, "", "")
+			return
+		}
+		serveErr <- srv.Serve(ln)
+	}()
+	opts.Ready.set(true)
+	logger.Printf("server: listening on %s", ln.Addr())
+
+	select {
+	case err := <-serveErr:
+		opts.Ready.set(false)
+		logger.Printf("server: %v", err)
+		return runHooks(opts, ExitServeError)
+	case sig := <-sigs:
+		logger.Printf("server: received %s, shutting down", sig)
+	case <-ctx.Done():
+		logger.Printf("server: %v, shutting down", context.Cause(ctx))
+	}
+
+	opts.Ready.set(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+	defer cancel()
+	// a second signal, during the readiness delay or the drain, means stop now
+	force := func(sig os.Signal) {
+		logger.Printf("server: received %s again, not waiting for requests", sig)
+		cancel()
+	}
+	if opts.ReadinessDelay > 0 {
+		select {
+		case <-time.After(opts.ReadinessDelay):
+		case sig := <-sigs:
+			force(sig)
+		}
+	}
+	go func() {
+		select {
+		case sig := <-sigs:
+			force(sig)
+		case <-shutdownCtx.Done():
+		}
+	}()
+
+	code := ExitOK
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Printf("server: drain incomplete: %v", err)
+		srv.Close()
+		code = ExitForced
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		logger.Printf("server: %v", err)
+	}
+	logger.Printf("server: stopped")
+	return runHooks(opts, code)
+}
+
+func runHooks(opts Options, code int) int {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.HookTimeout)
+	defer cancel()
+	for i := len(opts.Hooks) - 1; i >= 0; i-- {
+		h := opts.Hooks[i]
+		if err := h.Fn(ctx); err != nil {
+			opts.Logger.Printf("server: shutdown hook %s: %v", h.Name, err)
+			if code == ExitOK {
+				code = ExitHookFailed
+			}
+		}
+	}
+	return code
+}
+
+// Readiness is a readiness probe. It's also an http.Handler answering 200
+// while ready and 503 otherwise; mount it at e.g. /readyz.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+// Ready reports the current state.
+func (r *Readiness) Ready() bool { return r.ready.Load() }
+
+func (r *Readiness) set(v bool) { r.ready.Store(v) }
+
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if !r.Ready() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,231 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/midsane/go-playground/08-http-server/server"
+)
+
+// run is a server.Run going in the background.
+type run struct {
+	addr string
+	code chan int
+	// hooks records the hooks in the order they ran
+	mu    sync.Mutex
+	hooks []string
+}
+
+// logWriter hands every log line to lines.
+type logWriter chan string
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+// start runs Run with two recording hooks, "first" and "second",
+// registered ahead of opts.Hooks, and returns once it is listening.
+func start(t *testing.T, ctx context.Context, h http.Handler, opts server.Options) *run {
+	t.Helper()
+	r := &run{code: make(chan int, 1)}
+	var hooks []server.Hook
+	for _, name := range []string{"first", "second"} {
+		hooks = append(hooks, server.Hook{Name: name, Fn: func(context.Context) error {
+			r.mu.Lock()
+			r.hooks = append(r.hooks, name)
+			r.mu.Unlock()
+			return nil
+		}})
+	}
+	opts.Hooks = append(hooks, opts.Hooks...)
+	lines := make(logWriter, 100)
+	opts.Logger = log.New(lines, "", 0)
+	go func() { r.code <- server.Run(ctx, &http.Server{Addr: "127.0.0.1:0", Handler: h}, opts) }()
+	for {
+		select {
+		case l := <-lines:
+			if addr, ok := strings.CutPrefix(strings.TrimSpace(l), "server: listening on "); ok {
+				r.addr = addr
+				go func() {
+					for range lines {
+					}
+				}()
+				return r
+			}
+		case c := <-r.code:
+			t.Fatalf("Run returned %d before listening", c)
+		}
+	}
+}
+
+func (r *run) wait(t *testing.T) int {
+	t.Helper()
+	select {
+	case c := <-r.code:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return")
+		return -1
+	}
+}
+
+// slowHandler answers once release is closed or the request is canceled,
+// and reports each request on entered.
+type slowHandler struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newSlowHandler() *slowHandler {
+	return &slowHandler{entered: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (h *slowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.entered <- struct{}{}
+	select {
+	case <-h.release:
+		w.Write([]byte("done"))
+	case <-r.Context().Done():
+	}
+}
+
+func get(addr string) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		errc <- err
+	}()
+	return errc
+}
+
+func TestServeErrorOnBusyPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var ran []string
+	code := server.Run(context.Background(), &http.Server{Addr: ln.Addr().String()}, server.Options{
+		Logger: log.New(io.Discard, "", 0),
+		Hooks:  []server.Hook{{Name: "h", Fn: func(context.Context) error { ran = append(ran, "h"); return nil }}},
+	})
+	if code != server.ExitServeError {
+		t.Errorf("Run = %d, want ExitServeError", code)
+	}
+	if len(ran) != 1 {
+		t.Error("hooks didn't run after a serve error")
+	}
+}
+
+func TestDrainsThenRunsHooksInReverse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newSlowHandler()
+	ready := &server.Readiness{}
+	r := start(t, ctx, h, server.Options{Ready: ready, ReadinessDelay: 20 * time.Millisecond})
+	if !ready.Ready() {
+		t.Fatal("not ready while listening")
+	}
+
+	inflight := get(r.addr)
+	<-h.entered
+	cancel()
+	// readiness goes first, while the request is still being served
+	deadline := time.Now().Add(time.Second)
+	for ready.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("readiness never went false")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-inflight:
+		t.Fatalf("request finished before the drain: %v", err)
+	default:
+	}
+
+	close(h.release)
+	if err := <-inflight; err != nil {
+		t.Errorf("in-flight request: %v", err)
+	}
+	if code := r.wait(t); code != server.ExitOK {
+		t.Errorf("Run = %d, want ExitOK", code)
+	}
+	if want := []string{"second", "first"}; !slices.Equal(r.hooks, want) {
+		t.Errorf("hooks ran %v, want %v", r.hooks, want)
+	}
+}
+
+func TestForcedAfterDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	h := newSlowHandler()
+	defer close(h.release)
+	r := start(t, ctx, h, server.Options{ShutdownTimeout: 20 * time.Millisecond})
+
+	inflight := get(r.addr)
+	<-h.entered
+	cancel()
+	if code := r.wait(t); code != server.ExitForced {
+		t.Errorf("Run = %d, want ExitForced", code)
+	}
+	if err := <-inflight; err == nil {
+		t.Error("cut-off request succeeded")
+	}
+	if len(r.hooks) != 2 {
+		t.Errorf("hooks ran %v after a forced stop", r.hooks)
+	}
+}
+
+func TestHookFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := start(t, ctx, http.NotFoundHandler(), server.Options{Hooks: []server.Hook{
+		{Name: "broken", Fn: func(context.Context) error { return errors.New("flush failed") }},
+	}})
+	cancel()
+	if code := r.wait(t); code != server.ExitHookFailed {
+		t.Errorf("Run = %d, want ExitHookFailed", code)
+	}
+	// the failing hook runs first and doesn't stop the ones registered
+	// before it
+	if want := []string{"second", "first"}; !slices.Equal(r.hooks, want) {
+		t.Errorf("hooks ran %v", r.hooks)
+	}
+}
+
+func TestSignals(t *testing.T) {
+	h := newSlowHandler()
+	defer close(h.release)
+	r := start(t, context.Background(), h, server.Options{Signals: []os.Signal{syscall.SIGUSR1}})
+
+	inflight := get(r.addr)
+	<-h.entered
+	// the first signal starts a drain that would wait 30s; the second one
+	// cuts it short
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	time.Sleep(20 * time.Millisecond)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	if code := r.wait(t); code != server.ExitForced {
+		t.Errorf("Run = %d, want ExitForced", code)
+	}
+	<-inflight
+}
@@ -1,28 +1,53 @@
 package server
 
 import (
-	"context"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/midsane/go-playground/04-logging/audit"
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+	"github.com/midsane/go-playground/06-context/ctxkey"
+	"github.com/midsane/go-playground/08-http-server/httpx"
 )
 
 var jwtSecret = []byte("super-secret-key")
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// Claims is what LoginHandler signs and JWTMiddleware parses back, so the
+// two can't drift apart: the user id travels as "user_id", not in the
+// standard "jti" that StandardClaims.Id reads.
+type Claims struct {
+	UserID string `json:"user_id"`
+	jwt.StandardClaims
 }
 
+// auditLog gets logins and rejected tokens, apart from the access log. Start
+// opens it; nil (in tests, say) records nothing.
+var auditLog *audit.Logger
+
+func recordAudit(e audit.Event) {
+	if auditLog == nil {
+		return
+	}
+	if err := auditLog.Record(e); err != nil {
+		log.Println("audit:", err)
+	}
+}
 
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every rejection is an access denial for the audit trail too
+		deny := func(msg string) {
+			recordAudit(audit.Event{Action: "auth.token", Target: r.Method + " " + r.URL.Path, Outcome: audit.Denied,
+				Fields: map[string]any{"remote_addr": r.RemoteAddr, "reason": msg}})
+			writeError(w, r, apperr.New(apperr.Unauthorized, msg))
+		}
+
 		auth := r.Header.Get("Authorization")
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-				Error: "missing or invalid token",
-			})
+			deny("missing or invalid token")
 			return
 		}
 
@@ -31,26 +56,19 @@ func JWTMiddleware(next http.Handler) http.Handler {
 		// token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (any, error) {
 		// 	return jwtSecret, nil
 		// })
-		claims := jwt.StandardClaims{}
+		claims := Claims{}
 		token, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (any, error) {
 			return jwtSecret, nil
 		})
-		/*
-
-		 */
-		if err != nil || !token.Valid {
-			writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-				Error: "invalid token",
-			})
+		if err != nil || !token.Valid || claims.UserID == "" {
+			deny("invalid token")
 			return
 		}
-		userID := claims.Id
+		userID := claims.UserID
 
 		// claims, ok := token.Claims.(jwt.MapClaims)
 		// if !ok {
-		// 	writeJSON(w, http.StatusUnauthorized, ErrorResponse{
-		// 		Error: "invalid claims",
-		// 	})
+		// 	writeError(w, r, apperr.New(apperr.Unauthorized, "invalid claims"))
 		// }
 
 		/*if we want to avoid the jwt.MapClaims assertion here because of which
@@ -60,62 +78,61 @@ func JWTMiddleware(next http.Handler) http.Handler {
 		custom static type as we want so no assertion needed.
 		*/
 
-		ctx := context.WithValue(r.Context(), "user", userID)
+		//typed key instead of the "user" string -> no collisions, no assertion on read
+		httpx.SetLogUser(r.Context(), userID)
+		ctx := ctxkey.WithPrincipal(r.Context(), ctxkey.Principal{UserID: userID})
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
-			Error: "method not allowed",
-		})
-		return
-	}
-
 	type LoginRequest struct {
 		UserID string `json:"user_id"`
 	}
 
 	var req LoginRequest
 
-	if err := parseJSON(r, &req); err != nil {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: err.Error(),
-		})
+	if err := httpx.DecodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	if req.UserID == "" {
-		writeJSON(w, http.StatusBadRequest, ErrorResponse{
-			Error: "user_id required",
-		})
+		writeError(w, r, apperr.New(apperr.Invalid, "user_id required"))
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": req.UserID,
-		"exp":     time.Now().Add(time.Hour).Unix(),
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		UserID: req.UserID,
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
 	})
 
 	tokenStr, err := token.SignedString(jwtSecret)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{
-			Error: "failed to sign token",
-		})
+		recordAudit(audit.Event{Action: "auth.login", Actor: req.UserID, Outcome: audit.Failure,
+			Fields: map[string]any{"remote_addr": r.RemoteAddr, "reason": "sign token"}})
+		writeError(w, r, apperr.New(apperr.Internal, "failed to sign token"))
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
+	recordAudit(audit.Event{Action: "auth.login", Actor: req.UserID, Outcome: audit.Success,
+		Fields: map[string]any{"remote_addr": r.RemoteAddr}})
+	httpx.WriteJSON(w, http.StatusOK, map[string]string{
 		"token": tokenStr,
 	})
 }
 
 func ProfileHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value("user").(string)
+	principal, ok := ctxkey.PrincipalFrom(r.Context())
+	if !ok {
+		writeError(w, r, apperr.New(apperr.Unauthorized, "not authenticated"))
+		return
+	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"user_id": userID,
+	httpx.WriteJSON(w, http.StatusOK, map[string]string{
+		"user_id": principal.UserID,
 		"message": "protected profile data",
 	})
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func login(t *testing.T, userID string) string {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user_id":"`+userID+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	LoginHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login = %d %s", w.Code, w.Body)
+	}
+	var resp struct{ Token string }
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp.Token
+}
+
+func profile(token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	JWTMiddleware(http.HandlerFunc(ProfileHandler)).ServeHTTP(w, r)
+	return w
+}
+
+func sign(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLoginTokenAuthenticatesProfile(t *testing.T) {
+	w := profile(login(t, "u-42"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("profile = %d %s", w.Code, w.Body)
+	}
+	var body map[string]string
+	json.NewDecoder(w.Body).Decode(&body)
+	if body["user_id"] != "u-42" {
+		t.Errorf("profile user_id = %q, want the one logged in", body["user_id"])
+	}
+}
+
+func TestJWTMiddlewareRejects(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "u-42"}).SignedString([]byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"garbage": "not-a-jwt",
+		"expired": sign(t, Claims{UserID: "u-42", StandardClaims: jwt.StandardClaims{ExpiresAt: time.Now().Add(-time.Minute).Unix()}}),
+		// the id in "jti" where the old middleware looked for it
+		"no user_id":   sign(t, jwt.StandardClaims{Id: "u-42", ExpiresAt: exp}),
+		"wrong secret": forged,
+	} {
+		if w := profile(token); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: profile = %d, want 401", name, w.Code)
+		}
+	}
+}
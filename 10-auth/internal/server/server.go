@@ -1,38 +1,34 @@
 package server
 
 import (
-	"encoding/json"
-	"errors"
+	"cmp"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
+
+	"github.com/midsane/go-playground/04-logging/audit"
+	"github.com/midsane/go-playground/05-error-handling/apperr"
+	"github.com/midsane/go-playground/06-context/deadline"
+	"github.com/midsane/go-playground/08-http-server/cors"
+	"github.com/midsane/go-playground/08-http-server/httpx"
+	httpserver "github.com/midsane/go-playground/08-http-server/server"
 )
 
 type Server struct {
 	addr string
-	mux  *http.ServeMux
+	mux  *httpx.Router
 }
 
 func NewServer(addr string) *Server {
 	return &Server{
 		addr: addr,
-		mux:  &http.ServeMux{},
+		mux:  httpx.NewRouter(),
 	}
 }
 
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		oldTime := time.Now()
-		defer func() {
-			fmt.Printf("%s, %s ", r.Method, time.Since(oldTime))
-		}()
-
-		next.ServeHTTP(w, r)
-	})
-
-}
-
 func RecoveryMiddleWare(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -45,51 +41,86 @@ func RecoveryMiddleWare(next http.Handler) http.Handler {
 }
 
 func (s *Server) routes() {
-	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "welcome to mid auth")
 	})
 
-	s.mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+	s.mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintln(w, "welcome to mid auth login route")
 	})
+	s.mux.HandleFunc("POST /login", LoginHandler)
 
-	protectedProfile := Chain(
-		http.HandlerFunc(ProfileHandler),
-		JWTMiddleware,
-	)
-
-	s.mux.Handle("/profile", protectedProfile)
+	protected := s.mux.Group("/")
+	protected.Use("jwt", JWTMiddleware)
+	protected.HandleFunc("GET /profile", ProfileHandler)
 }
 
-func writeJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if apperr.KindOf(err) == apperr.Internal {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	apperr.Write(w, r, err)
 }
 
-func parseJSON(r *http.Request, dst interface{}) error {
-	if r.Header.Get("Content-Type") != "application/json" {
-		return errors.New("content type must be application/json")
-	}
-	return json.NewDecoder(r.Body).Decode(dst)
+// Config is what Start needs.
+type Config struct {
+	Addr string
+	// AuditLog is this binary's own file by default, so it never shares a
+	// chain with another server started in the same directory.
+	AuditLog string
+	CORS     cors.Config
 }
 
-func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler{
-	for i := len(middlewares)-1; i>=0; i--{
-		h = middlewares[i](h)
+// LoadConfig returns the defaults for serving on addr, with AUDIT_LOG and
+// the CORS_* variables (see cors.FromEnv) applied.
+func LoadConfig(addr string) (Config, error) {
+	cfg := Config{
+		Addr:     addr,
+		AuditLog: cmp.Or(os.Getenv("AUDIT_LOG"), "auth-audit.log"),
+		CORS: cors.Config{
+			AllowedOrigins: []string{"http://localhost:3000"},
+			AllowedMethods: []string{http.MethodGet, http.MethodPost},
+			AllowedHeaders: []string{"Authorization", "Content-Type", httpx.RequestIDHeader, deadline.Header},
+			ExposedHeaders: []string{httpx.RequestIDHeader},
+			MaxAge:         10 * time.Minute,
+		},
 	}
-	return h
+	var err error
+	cfg.CORS, err = cors.FromEnv(cfg.CORS)
+	return cfg, err
 }
 
-func Start(addr string) {
-	srv := NewServer(addr)
+// Start serves until SIGINT/SIGTERM, drains in-flight requests and returns
+// the process exit code.
+func Start(cfg Config) int {
+	srv := NewServer(cfg.Addr)
+	ready := &httpserver.Readiness{}
+	srv.mux.Handle("GET /readyz", ready)
 	srv.routes()
 
-	fmt.Println("server is running on port ", addr)
-	finalHandler := Chain(
-		srv.mux,
-		RecoveryMiddleWare,
-		LoggingMiddleware,
-	)
-	log.Fatal(http.ListenAndServe(addr, finalHandler))
+	corsMW, err := cors.New(cfg.CORS)
+	if err != nil {
+		log.Println(err)
+		return httpserver.ExitServeError
+	}
+
+	al, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		log.Println(err)
+		return httpserver.ExitServeError
+	}
+	auditLog = al
+
+	finalHandler := httpx.NewChain().
+		Use("request-id", httpx.RequestID).
+		Use("log", httpx.AccessLog(httpx.AccessLogOptions{})).
+		Use("cors", corsMW.Handler).
+		Use("recover", RecoveryMiddleWare).
+		Use("deadline", deadline.Middleware(deadline.Policy{Default: 10 * time.Second, Max: 30 * time.Second})).
+		Then(srv.mux)
+	return httpserver.Run(context.Background(), &http.Server{Addr: cfg.Addr, Handler: finalHandler}, httpserver.Options{
+		ShutdownTimeout: 30 * time.Second,
+		Ready:           ready,
+		Hooks:           []httpserver.Hook{{Name: "audit log", Fn: func(context.Context) error { return al.Close() }}},
+	})
 }
@@ -1,6 +1,11 @@
 package main
 
-import "github.com/midsane/go-playground/10-auth/internal/server"
+import (
+	"log"
+	"os"
+
+	"github.com/midsane/go-playground/10-auth/internal/server"
+)
 
 const PORT = ":8080"
 
@@ -9,5 +14,9 @@ lets start a basic net/http server and do jwt authentication
 */
 
 func main() {
-	server.Start(PORT)
+	cfg, err := server.LoadConfig(PORT)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(server.Start(cfg))
 }
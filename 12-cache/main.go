@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/midsane/go-playground/07-concurrency/cache"
+)
+
+/*
+cache-aside: look in the cache first, on a miss load from the slow store and
+put the result in the cache for next time. the cache is bounded so memory
+can't grow forever, and entries expire so stale data eventually goes away.
+*/
+
+type User struct {
+	ID   int
+	Name string
+}
+
+// slowDB stands in for a real database.
+func slowDB(id int) (User, error) {
+	time.Sleep(50 * time.Millisecond)
+	return User{ID: id, Name: fmt.Sprintf("user-%d", id)}, nil
+}
+
+type UserStore struct {
+	cache *cache.Cache[int, User]
+}
+
+func (s *UserStore) Get(id int) (User, error) {
+	u, err := s.cache.Get(id)
+	if err == nil {
+		return u, nil
+	}
+	if !errors.Is(err, cache.ErrNotFound) {
+		return User{}, err
+	}
+	u, err = slowDB(id)
+	if err != nil {
+		return User{}, err
+	}
+	s.cache.Set(id, u)
+	return u, nil
+}
+
+func main() {
+	c := cache.New(cache.Options[int, User]{
+		MaxEntries:      2,
+		Policy:          cache.LRU,
+		TTL:             time.Second,
+		CleanupInterval: 500 * time.Millisecond,
+		OnEvict: func(id int, _ User, reason cache.Reason) {
+			fmt.Printf("dropped user %d (%s)\n", id, reason)
+		},
+	})
+	defer c.Close()
+	store := &UserStore{cache: c}
+
+	for _, id := range []int{1, 2, 1, 3, 2, 1} {
+		start := time.Now()
+		u, _ := store.Get(id)
+		fmt.Printf("got %s in %v\n", u.Name, time.Since(start).Round(time.Millisecond))
+	}
+
+	time.Sleep(2 * time.Second)
+	s := c.Stats()
+	fmt.Printf("hits=%d misses=%d evictions=%d expired=%d ratio=%.2f\n",
+		s.Hits, s.Misses, s.Evictions, s.Expired, s.HitRatio())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/midsane/go-playground/08-http-server/server"
+	"github.com/midsane/go-playground/20-observability/errreport/collector"
+)
+
+/*
+local error collector -> services send errreport events to POST /api/events,
+open http://localhost:9900 to see them grouped by fingerprint.
+
+	go run ./20-observability/cmd/collector -addr :9900 -data ./errors
+*/
+
+func main() {
+	addr := flag.String("addr", ":9900", "listen address")
+	dir := flag.String("data", "errors", "directory for the event store")
+	flag.Parse()
+
+	store, err := collector.OpenFileStore(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{
+		Addr:         *addr,
+		Handler:      collector.NewServer(store),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	log.Println("collector running on", *addr)
+	// the store is closed once the last in-flight event is written
+	os.Exit(server.Run(context.Background(), srv, server.Options{
+		ShutdownTimeout: 15 * time.Second,
+		Hooks: []server.Hook{
+			{Name: "event store", Fn: func(context.Context) error { return store.Close() }},
+		},
+	}))
+}
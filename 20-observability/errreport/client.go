@@ -0,0 +1,220 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/midsane/go-playground/04-logging/redact"
+	"github.com/midsane/go-playground/07-concurrency/safego"
+)
+
+// Options configures a Client.
+type Options struct {
+	// Endpoint is the collector's event URL, e.g. http://localhost:9900/api/events.
+	Endpoint    string
+	Release     string
+	Environment string
+	// QueueSize bounds the events waiting to be sent; extra events are
+	// dropped rather than blocking the request that produced them. 100 if zero.
+	QueueSize int
+	// HTTPClient defaults to one with a 5s timeout.
+	HTTPClient *http.Client
+}
+
+// Client sends events to the collector from a background goroutine.
+type Client struct {
+	opts     Options
+	hostname string
+	redactor *redact.Redactor
+	queue    chan Event
+	done     chan struct{}
+	closeMu  sync.RWMutex
+	closed   bool
+}
+
+// New starts a Client.
+func New(opts Options) *Client {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	host, _ := os.Hostname()
+	c := &Client{
+		opts:     opts,
+		hostname: host,
+		redactor: redact.New(redact.Options{}),
+		queue:    make(chan Event, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// CaptureError reports err. r may be nil. The stack is taken from err when
+// it carries one (anything that prints it with %+v), otherwise from here.
+func (c *Client) CaptureError(r *http.Request, err error) {
+	if c == nil || err == nil {
+		return
+	}
+	stack := fmt.Sprintf("%+v", err)
+	if stack == err.Error() {
+		stack = string(debug.Stack())
+	}
+	c.send(Event{
+		Level:   LevelError,
+		Type:    errorType(err),
+		Message: err.Error(),
+		Stack:   stack,
+		Request: c.request(r),
+	})
+}
+
+// CapturePanic reports a recovered panic value with the stack captured by
+// the recovering code.
+func (c *Client) CapturePanic(r *http.Request, value any, stack []byte) {
+	if c == nil {
+		return
+	}
+	c.send(Event{
+		Level:   LevelFatal,
+		Type:    "panic",
+		Message: fmt.Sprint(value),
+		Stack:   string(stack),
+		Request: c.request(r),
+	})
+}
+
+// Hook adapts the client to safego.SetHook, so panics in background
+// goroutines are reported too.
+func (c *Client) Hook() safego.Hook {
+	return func(ctx context.Context, err error) {
+		var perr *safego.PanicError
+		if errors.As(err, &perr) {
+			c.CapturePanic(nil, perr.Value, perr.Stack)
+			return
+		}
+		c.CaptureError(nil, err)
+	}
+}
+
+func (c *Client) send(e Event) {
+	e.ID = newID()
+	e.Timestamp = time.Now().UTC()
+	e.Release = c.opts.Release
+	e.Environment = c.opts.Environment
+	e.ServerName = c.hostname
+	// the stack has the message in it too (%+v of a wrapped error, or the
+	// panic value), so it needs the same treatment
+	e.Message = c.redactor.String(e.Message)
+	e.Stack = c.redactor.String(e.Stack)
+	e.Fingerprint = Fingerprint(e)
+
+	c.closeMu.RLock()
+	defer c.closeMu.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.queue <- e:
+	default:
+		slog.Warn("errreport: queue full, dropping event", "fingerprint", e.Fingerprint)
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+	for e := range c.queue {
+		if err := c.post(e); err != nil {
+			slog.Warn("errreport: sending event", "err", err)
+		}
+	}
+}
+
+func (c *Client) post(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := c.opts.HTTPClient.Post(c.opts.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for the queued ones to be sent,
+// or for ctx to end.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeMu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.closeMu.Unlock()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// request keeps the parts of r worth having in an event. Headers go through
+// the same redaction as the logs, so no tokens or cookies leave the process.
+func (c *Client) request(r *http.Request) *Request {
+	if r == nil {
+		return nil
+	}
+	req := &Request{
+		Method:    r.Method,
+		URL:       c.redactor.String(r.URL.String()),
+		Headers:   map[string]string{},
+		RequestID: r.Header.Get("X-Request-ID"),
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		req.RemoteIP = host
+	}
+	for k := range r.Header {
+		if a := c.redactor.Attr(slog.String(k, r.Header.Get(k))); a.Key != "" {
+			req.Headers[k] = a.Value.String()
+		}
+	}
+	return req
+}
+
+// errorType names the innermost error in the chain, which is usually the
+// one that says what actually went wrong.
+func errorType(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return reflect.TypeOf(err).String()
+		}
+		err = next
+	}
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/midsane/go-playground/08-http-server/httpx"
+	"github.com/midsane/go-playground/20-observability/errreport"
+)
+
+// maxEventSize bounds a single event body; stacks of a few hundred frames
+// fit comfortably.
+const maxEventSize = 1 << 20
+
+// Server is the collector's HTTP API and UI:
+//
+//	POST /api/events              ingest one event
+//	GET  /api/issues              issues as JSON
+//	GET  /api/issues/{fingerprint} one issue and its events as JSON
+//	GET  /                        issue list
+//	GET  /issues/{fingerprint}    issue detail
+type Server struct {
+	store *FileStore
+	mux   *http.ServeMux
+}
+
+// NewServer serves store.
+func NewServer(store *FileStore) *Server {
+	s := &Server{store: store, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /api/events", s.ingest)
+	s.mux.HandleFunc("GET /api/issues", s.listJSON)
+	s.mux.HandleFunc("GET /api/issues/{fingerprint}", s.issueJSON)
+	s.mux.HandleFunc("GET /{$}", s.listHTML)
+	s.mux.HandleFunc("GET /issues/{fingerprint}", s.issueHTML)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) ingest(w http.ResponseWriter, r *http.Request) {
+	var e errreport.Event
+	// unknown fields are fine, clients may be newer than the collector
+	dec := httpx.Decoder{MaxBytes: maxEventSize, AllowUnknownFields: true}
+	if err := dec.Decode(w, r, &e); err != nil {
+		var de *httpx.DecodeError
+		if !errors.As(err, &de) {
+			// Decode documents only *DecodeError; anything else is our bug
+			log.Println("collector: decoding event:", err)
+			http.Error(w, "could not read event", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "invalid event: "+de.Msg, de.Status())
+		return
+	}
+	if e.Message == "" && e.Stack == "" {
+		http.Error(w, "event needs a message or a stack", http.StatusBadRequest)
+		return
+	}
+	if err := s.store.Add(e); err != nil {
+		log.Println("collector: storing event:", err)
+		http.Error(w, "could not store event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) listJSON(w http.ResponseWriter, r *http.Request) {
+	httpx.WriteJSON(w, http.StatusOK, s.store.Issues())
+}
+
+func (s *Server) issueJSON(w http.ResponseWriter, r *http.Request) {
+	is, events, ok := s.store.Issue(r.PathValue("fingerprint"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	httpx.WriteJSON(w, http.StatusOK, map[string]any{"issue": is, "events": events})
+}
+
+func (s *Server) listHTML(w http.ResponseWriter, r *http.Request) {
+	render(w, listTmpl, s.store.Issues())
+}
+
+func (s *Server) issueHTML(w http.ResponseWriter, r *http.Request) {
+	is, events, ok := s.store.Issue(r.PathValue("fingerprint"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	render(w, issueTmpl, map[string]any{"Issue": is, "Events": events})
+}
+
+func render(w http.ResponseWriter, t *template.Template, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		log.Println("collector: rendering:", err)
+	}
+}
+
+const style = `<style>
+body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;width:100%}
+td,th{border-bottom:1px solid #ddd;padding:.4em;text-align:left;vertical-align:top}
+pre{background:#f6f6f6;padding:1em;overflow-x:auto}.fatal{color:#b00}.error{color:#c60}
+</style>`
+
+var listTmpl = template.Must(template.New("list").Parse(`<!doctype html>
+<title>errors</title>` + style + `
+<h1>Issues</h1>
+<table>
+<tr><th>Issue</th><th>Level</th><th>Events</th><th>First seen</th><th>Last seen</th><th>Releases</th></tr>
+{{range .}}<tr>
+<td><a href="/issues/{{.Fingerprint}}">{{.Type}}</a><br>{{.Message}}</td>
+<td class="{{.Level}}">{{.Level}}</td><td>{{.Count}}</td>
+<td>{{.FirstSeen.Format "2006-01-02 15:04:05"}}</td><td>{{.LastSeen.Format "2006-01-02 15:04:05"}}</td>
+<td>{{range .Releases}}{{.}} {{end}}</td>
+</tr>{{else}}<tr><td colspan="6">no errors yet</td></tr>{{end}}
+</table>`))
+
+var issueTmpl = template.Must(template.New("issue").Parse(`<!doctype html>
+<title>{{.Issue.Type}}</title>` + style + `
+<p><a href="/">&larr; issues</a></p>
+<h1>{{.Issue.Type}}</h1>
+<p>{{.Issue.Message}}</p>
+<p>{{.Issue.Count}} events, first seen {{.Issue.FirstSeen.Format "2006-01-02 15:04:05"}}, last seen {{.Issue.LastSeen.Format "2006-01-02 15:04:05"}}. Fingerprint <code>{{.Issue.Fingerprint}}</code></p>
+{{range .Events}}
+<h3>{{.Timestamp.Format "2006-01-02 15:04:05.000"}} &middot; {{.Release}} {{.Environment}} {{.ServerName}}</h3>
+<p>{{.Message}}</p>
+{{with .Request}}<p><code>{{.Method}} {{.URL}}</code> from {{.RemoteIP}} {{with .RequestID}}(request {{.}}){{end}}</p>
+<table>{{range $k, $v := .Headers}}<tr><td>{{$k}}</td><td>{{$v}}</td></tr>{{end}}</table>{{end}}
+<pre>{{.Stack}}</pre>
+{{end}}`))
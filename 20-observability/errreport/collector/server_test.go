@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	store, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewServer(store)
+}
+
+func post(s *Server, contentType, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestIngestRejectsBadBodies(t *testing.T) {
+	s := newTestServer(t)
+	for _, tc := range []struct {
+		name, contentType, body string
+		status                  int
+		msg                     string
+	}{
+		{"malformed", "application/json", `{"message": "boom"`, http.StatusBadRequest, "invalid event: request body contains malformed JSON"},
+		{"wrong type", "application/json", `{"message": 42}`, http.StatusBadRequest, `invalid event: field "message" must be a JSON string`},
+		{"two values", "application/json", `{"message": "a"} {}`, http.StatusBadRequest, "invalid event: request body must contain a single JSON value"},
+		{"not JSON", "text/plain", `boom`, http.StatusUnsupportedMediaType, "invalid event: Content-Type must be application/json"},
+		{"too large", "application/json", `{"message": "` + strings.Repeat("x", maxEventSize) + `"}`, http.StatusRequestEntityTooLarge, "invalid event: request body must not be larger than"},
+		{"empty event", "application/json", `{"level": "error"}`, http.StatusBadRequest, "event needs a message or a stack"},
+	} {
+		w := post(s, tc.contentType, tc.body)
+		if w.Code != tc.status || !strings.HasPrefix(w.Body.String(), tc.msg) {
+			t.Errorf("%s: %d %q, want %d %q", tc.name, w.Code, w.Body.String(), tc.status, tc.msg)
+		}
+	}
+	if issues := s.store.Issues(); len(issues) != 0 {
+		t.Errorf("rejected events were stored: %v", issues)
+	}
+}
+
+func TestIngestStoresEvent(t *testing.T) {
+	s := newTestServer(t)
+	// a field this collector doesn't know yet is fine
+	w := post(s, "application/json", `{"type": "*errors.errorString", "message": "boom", "sdk": "v2"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("ingest = %d %s", w.Code, w.Body)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/issues", nil)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	var issues []Issue
+	if err := json.NewDecoder(w.Body).Decode(&issues); err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Message != "boom" || issues[0].Count != 1 {
+		t.Errorf("issues = %+v", issues)
+	}
+}
@@ -0,0 +1,186 @@
+// Package collector receives errreport events, groups them into issues by
+// fingerprint and serves a small UI over them. Events are kept in an
+// append-only NDJSON file, so the collector survives restarts without a
+// database.
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/midsane/go-playground/20-observability/errreport"
+)
+
+// Issue is every event sharing a fingerprint.
+type Issue struct {
+	Fingerprint string    `json:"fingerprint"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	Level       string    `json:"level"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Releases    []string  `json:"releases"`
+}
+
+// FileStore keeps events in <dir>/events.ndjson and an in-memory index
+// built from it.
+type FileStore struct {
+	// MaxEventsPerIssue bounds the events kept in memory per issue for the
+	// detail page. The file keeps everything.
+	MaxEventsPerIssue int
+
+	mu     sync.RWMutex
+	f      *os.File
+	issues map[string]*Issue
+	events map[string][]errreport.Event
+}
+
+// OpenFileStore opens (or creates) the store in dir and replays the events
+// already in it.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "events.ndjson")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{
+		MaxEventsPerIssue: 50,
+		f:                 f,
+		issues:            map[string]*Issue{},
+		events:            map[string][]errreport.Event{},
+	}
+
+	if err := s.replay(path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay indexes the events in the file. A last line without its newline
+// is a write cut short by a crash; it is cut off so the next event starts
+// on a line of its own. Anything else that doesn't parse is an error.
+func (s *FileStore) replay(path string) error {
+	br := bufio.NewReader(s.f)
+	var good int64 // end of the last complete line
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			if len(b) == 0 {
+				return nil
+			}
+			log.Printf("collector: %s line %d is incomplete, dropping %d bytes", path, line, len(b))
+			return s.f.Truncate(good)
+		}
+		if err != nil {
+			return err
+		}
+		var e errreport.Event
+		if err := json.Unmarshal(b, &e); err != nil {
+			return fmt.Errorf("collector: %s line %d: %w", path, line, err)
+		}
+		s.index(e)
+		good += int64(len(b))
+	}
+}
+
+// Add stores e, filling in the fingerprint if the client didn't.
+func (s *FileStore) Add(e errreport.Event) error {
+	if e.Fingerprint == "" {
+		e.Fingerprint = errreport.Fingerprint(e)
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.index(e)
+	return nil
+}
+
+// index must be called with mu held (or during replay).
+func (s *FileStore) index(e errreport.Event) {
+	is, ok := s.issues[e.Fingerprint]
+	if !ok {
+		is = &Issue{Fingerprint: e.Fingerprint, Type: e.Type, FirstSeen: e.Timestamp}
+		s.issues[e.Fingerprint] = is
+	}
+	is.Count++
+	if !e.Timestamp.Before(is.LastSeen) {
+		is.LastSeen = e.Timestamp
+		is.Message = e.Message
+		is.Level = e.Level
+	}
+	if e.Timestamp.Before(is.FirstSeen) {
+		is.FirstSeen = e.Timestamp
+	}
+	if e.Release != "" && !slices.Contains(is.Releases, e.Release) {
+		is.Releases = append(is.Releases, e.Release)
+	}
+
+	evs := append(s.events[e.Fingerprint], e)
+	if limit := s.MaxEventsPerIssue; limit > 0 && len(evs) > limit {
+		evs = evs[len(evs)-limit:]
+	}
+	s.events[e.Fingerprint] = evs
+}
+
+// Issues returns every issue, most recently seen first.
+func (s *FileStore) Issues() []Issue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Issue, 0, len(s.issues))
+	for _, is := range s.issues {
+		c := *is
+		c.Releases = append([]string(nil), is.Releases...)
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// Issue returns one issue and its latest events, newest first.
+func (s *FileStore) Issue(fingerprint string) (Issue, []errreport.Event, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	is, ok := s.issues[fingerprint]
+	if !ok {
+		return Issue{}, nil, false
+	}
+	evs := s.events[fingerprint]
+	out := make([]errreport.Event, len(evs))
+	for i, e := range evs {
+		out[len(evs)-1-i] = e
+	}
+	c := *is
+	c.Releases = append([]string(nil), is.Releases...)
+	return c, out, true
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
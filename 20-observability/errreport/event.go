@@ -0,0 +1,88 @@
+// Package errreport sends errors and recovered panics to a local collector
+// (see the collector package and cmd/collector), Sentry style but without
+// any external service.
+package errreport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event is one reported error, as sent over the wire.
+type Event struct {
+	ID          string            `json:"event_id"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Level       string            `json:"level"`
+	Type        string            `json:"type"`
+	Message     string            `json:"message"`
+	Stack       string            `json:"stack,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Request     *Request          `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Request is the HTTP request an event happened in.
+type Request struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	RemoteIP  string            `json:"remote_ip,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+// Levels.
+const (
+	LevelError = "error"
+	LevelFatal = "fatal"
+)
+
+// frameFunc matches the function lines of a debug.Stack() or %+v trace,
+// e.g. "main.handler(0xc000010000)" or "\tmain.handler".
+var frameFunc = regexp.MustCompile(`^\s*([\w./*()-]+\.[\w.*()-]+?)(?:\(.*\))?$`)
+
+// skipFrame leaves out frames that are the same for every panic.
+func skipFrame(fn string) bool {
+	for _, p := range []string{"runtime.", "runtime/debug.", "net/http.", "github.com/midsane/go-playground/07-concurrency/safego.", "github.com/midsane/go-playground/20-observability/errreport."} {
+		if strings.HasPrefix(fn, p) {
+			return true
+		}
+	}
+	return fn == "panic"
+}
+
+// Fingerprint groups events that are "the same bug": the error type plus
+// the top few application frames. Line numbers and the message are left out
+// on purpose so a reworded message or an unrelated edit above the crash
+// site doesn't open a new issue. Without a stack it falls back to the
+// message.
+func Fingerprint(e Event) string {
+	const maxFrames = 5
+	var frames []string
+	for _, line := range strings.Split(e.Stack, "\n") {
+		if strings.Contains(line, ".go:") || strings.HasPrefix(line, "goroutine ") {
+			continue
+		}
+		m := frameFunc.FindStringSubmatch(line)
+		if m == nil || skipFrame(m[1]) {
+			continue
+		}
+		frames = append(frames, m[1])
+		if len(frames) == maxFrames {
+			break
+		}
+	}
+	parts := []string{e.Type}
+	if len(frames) > 0 {
+		parts = append(parts, frames...)
+	} else {
+		parts = append(parts, e.Message)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:8])
+}